@@ -44,6 +44,12 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 	for _, err := range iter.Iterate(ctx, paths...) {
 
 		if err != nil {
+
+			if iterate.IsNotFound(err) {
+				slog.Warn("Record not found, skipping", "error", err)
+				continue
+			}
+
 			return err
 		}
 
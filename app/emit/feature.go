@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	// "sync"
 	"sync/atomic"
 
@@ -68,6 +69,12 @@ func (pub *FeatureEmitter) Emit(ctx context.Context, iterator_uri string, uris .
 		}
 
 		if err != nil {
+
+			if iterate.IsNotFound(err) {
+				slog.Warn("Record not found, skipping", "error", err)
+				continue
+			}
+
 			return atomic.LoadInt64(&count_bytes), err
 		}
 
@@ -0,0 +1,449 @@
+package iterate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// AUTO_SNIFF_SIZE is the maximum number of (decompressed) bytes inspected to determine the format of a file.
+const AUTO_SNIFF_SIZE int = 1024 * 1024
+
+var magic_zip = []byte("PK\x03\x04")
+var magic_tar = []byte("ustar")
+var utf8_bom = []byte{0xEF, 0xBB, 0xBF}
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "auto", NewAutoIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AutoIterator implements the `Iterator` interface for crawling URIs whose format is not known in advance. The
+// format of each URI is detected by inspecting it and the URI is then dispatched to the matching iterator.
+type AutoIterator struct {
+	Iterator
+	// query is the query string, minus any parameters handled by the `concurrentIterator` wrapper, used to create child iterators.
+	query url.Values
+	// reader_options is a `ReaderOptions` instance used to configure how files are read when detecting their format.
+	reader_options *ReaderOptions
+	// iterators is a map of child iterators, keyed by their URI.
+	iterators map[string]Iterator
+	// mu is a `sync.Mutex` used to guard access to 'iterators'.
+	mu *sync.Mutex
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewAutoIterator() returns a new `AutoIterator` instance configured by 'uri' in the form of:
+//
+//	auto://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be any of the parameters supported by the iterators that URIs are dispatched to, for example:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each file. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+//
+// Each URI is dispatched according to the following rules, in order:
+// * Directories are crawled using the `directory://` iterator.
+// * Zip archives are crawled using the `zip://` iterator.
+// * Tar archives (compressed or not) are crawled using the `tar://` iterator.
+// * Files whose first (non-whitespace) character is an ASCII record separator (0x1E) are assumed to be RFC 8142 GeoJSON text sequences and are crawled using the `geojsonseq://` iterator.
+// * Files whose first (non-whitespace) character is not "{", "[" or an ASCII record separator are assumed to be lists of files and are crawled using the `filelist://` iterator, unless they contain binary data in which case an error is returned.
+// * Files whose first character is "[" are assumed to be JSON arrays and their elements are crawled using the `jsonpath://?path=@this` iterator.
+// * Files containing more than one JSON document separated by newlines are crawled using the `geojsonl://` iterator.
+// * Files containing a document with a top-level "features" property or whose "type" is "FeatureCollection" are crawled using the `featurecollection://` iterator.
+// * All other files are assumed to be individual records and are crawled using the `file://` iterator.
+//
+// Files are decompressed before being inspected. The detected format of each URI is reported in the debug log. Since
+// `STDIN` can not be rewound after it has been inspected it is not supported. Remote (http:// or https://) URIs are
+// requested twice: once to detect their format and again by the iterator they are dispatched to.
+func NewAutoIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	// Files dispatched to the geojsonl:// iterator may be split, which can not be retried
+
+	_, err = splitFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	child_q := url.Values{}
+
+	for k, v := range q {
+
+		if strings.HasPrefix(k, "_") {
+			continue
+		}
+
+		child_q[k] = v
+	}
+
+	it := &AutoIterator{
+		query:          child_q,
+		reader_options: reader_options,
+		iterators:      make(map[string]Iterator),
+		mu:             new(sync.Mutex),
+		iterating:      new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *AutoIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		logger := slog.Default()
+
+		for _, uri := range uris {
+
+			scheme, params, err := detectFormat(ctx, uri, it.reader_options)
+
+			if err != nil {
+
+				if !yield(nil, fmt.Errorf("Failed to detect format for '%s', %w", uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			logger.Debug("Detected format", "uri", uri, "format", scheme)
+
+			child, err := it.childIterator(ctx, scheme, params)
+
+			if err != nil {
+
+				if !yield(nil, fmt.Errorf("Failed to create %s iterator for '%s', %w", scheme, uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			for rec, err := range child.Iterate(ctx, uri) {
+
+				if !yield(rec, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// childIterator returns the (cached) iterator for 'scheme' configured with the iterator's query parameters and 'params'.
+func (it *AutoIterator) childIterator(ctx context.Context, scheme string, params url.Values) (Iterator, error) {
+
+	q := url.Values{}
+
+	for k, v := range it.query {
+		q[k] = v
+	}
+
+	for k, v := range params {
+		q[k] = v
+	}
+
+	child_uri := fmt.Sprintf("%s://?%s", scheme, q.Encode())
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	child, ok := it.iterators[child_uri]
+
+	if ok {
+		return child, nil
+	}
+
+	child, err := newUnwrappedIterator(ctx, child_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	it.iterators[child_uri] = child
+	return child, nil
+}
+
+// detectFormat returns the scheme of the iterator, and any additional query parameters, that should be used to crawl 'uri'.
+func detectFormat(ctx context.Context, uri string, opts *ReaderOptions) (string, url.Values, error) {
+
+	// STDIN can not be rewound after it has been inspected so there would be nothing left for the child iterator to read
+	if uri == STDIN {
+		return "", nil, fmt.Errorf("The format of STDIN can not be detected, use an explicit iterator scheme instead")
+	}
+
+	if !isRemotePath(uri) {
+
+		info, err := os.Stat(uri)
+
+		if err != nil {
+			return "", nil, fmt.Errorf("Failed to stat '%s', %w", uri, err)
+		}
+
+		if info.IsDir() {
+			return "directory", nil, nil
+		}
+
+		is_zip, err := hasMagicBytes(uri, magic_zip)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		if is_zip {
+			return "zip", nil, nil
+		}
+	}
+
+	r, err := ReaderWithPathAndOptions(ctx, uri, opts)
+
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to create reader, %w", err)
+	}
+
+	defer r.Close()
+
+	br := bufio.NewReaderSize(r, AUTO_SNIFF_SIZE)
+	buf, err := br.Peek(AUTO_SNIFF_SIZE)
+
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", nil, fmt.Errorf("Failed to read '%s', %w", uri, err)
+	}
+
+	if len(buf) >= 262 && bytes.Equal(buf[257:262], magic_tar) {
+		return "tar", nil, nil
+	}
+
+	body := bytes.TrimLeft(bytes.TrimPrefix(buf, utf8_bom), " \t\r\n")
+
+	if len(body) == 0 {
+		return "", nil, fmt.Errorf("File is empty")
+	}
+
+	switch body[0] {
+	case '{':
+		return detectJSONFormat(body), nil, nil
+	case RECORD_SEPARATOR:
+		return "geojsonseq", nil, nil
+	case '[':
+		return "jsonpath", url.Values{"path": []string{"@this"}}, nil
+	default:
+
+		if !isText(buf, len(buf) == AUTO_SNIFF_SIZE) {
+			return "", nil, fmt.Errorf("Unrecognized binary format")
+		}
+
+		return "filelist", nil, nil
+	}
+}
+
+// isText returns a boolean value indicating whether 'buf' appears to contain (UTF-8 encoded) text rather than binary
+// data. If 'truncated' is true a partial character at the end of 'buf' is ignored.
+func isText(buf []byte, truncated bool) bool {
+
+	if bytes.IndexByte(buf, 0x00) != -1 {
+		return false
+	}
+
+	if truncated {
+
+		// Trim (at most) the leading bytes of a multi-byte character split by the end of the buffer
+
+		for i := 1; i < utf8.UTFMax && i <= len(buf); i++ {
+
+			if utf8.RuneStart(buf[len(buf)-i]) {
+
+				if !utf8.FullRune(buf[len(buf)-i:]) {
+					buf = buf[:len(buf)-i]
+				}
+
+				break
+			}
+		}
+	}
+
+	return utf8.Valid(buf)
+}
+
+// detectJSONFormat returns the scheme of the iterator that should be used to crawl the JSON document(s) that start
+// with 'body' which may be truncated.
+func detectJSONFormat(body []byte) string {
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	// Skip the opening "{"
+	_, err := dec.Token()
+
+	if err != nil {
+		return "file"
+	}
+
+	doc_type := ""
+	has_features := false
+	complete := false
+
+scan:
+	for {
+
+		t, err := dec.Token()
+
+		if err != nil {
+			break scan
+		}
+
+		if t == json.Delim('}') {
+			complete = true
+			break scan
+		}
+
+		key, ok := t.(string)
+
+		if !ok {
+			break scan
+		}
+
+		// The value of "features" may be truncated
+		if key == "features" {
+			has_features = true
+		}
+
+		// Values are always decoded in full, even if they are not strings, so the decoder stays in sync
+
+		var raw json.RawMessage
+
+		err = dec.Decode(&raw)
+
+		if err != nil {
+			break scan
+		}
+
+		if key == "type" {
+
+			var str string
+
+			if json.Unmarshal(raw, &str) == nil {
+				doc_type = str
+			}
+		}
+	}
+
+	if complete {
+
+		rest := body[dec.InputOffset():]
+		trimmed := bytes.TrimLeft(rest, " \t\r")
+
+		if bytes.HasPrefix(trimmed, []byte("\n")) && bytes.HasPrefix(bytes.TrimLeft(trimmed, " \t\r\n"), []byte("{")) {
+			return "geojsonl"
+		}
+	}
+
+	if has_features || doc_type == "FeatureCollection" {
+		return "featurecollection"
+	}
+
+	return "file"
+}
+
+// hasMagicBytes returns a boolean value indicating whether the (local) file at 'path' starts with 'magic'.
+func hasMagicBytes(path string, magic []byte) (bool, error) {
+
+	r, err := os.Open(path)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to open '%s', %w", path, err)
+	}
+
+	defer r.Close()
+
+	buf := make([]byte, len(magic))
+
+	_, err = io.ReadFull(r, buf)
+
+	if err != nil {
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Failed to read '%s', %w", path, err)
+	}
+
+	return bytes.Equal(buf, magic), nil
+}
+
+// Seen() returns the total number of records processed so far by all the child iterators.
+func (it *AutoIterator) Seen() int64 {
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	seen := int64(0)
+
+	for _, child := range it.iterators {
+		seen += child.Seen()
+	}
+
+	return seen
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *AutoIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *AutoIterator) Close() error {
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	for child_uri, child := range it.iterators {
+
+		err := child.Close()
+
+		if err != nil {
+			return fmt.Errorf("Failed to close '%s', %w", child_uri, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,338 @@
+package iterate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newAutoFixtures writes files, in formats not covered by the "fixtures" directory, to a temporary directory and
+// returns a map of their paths and the number of records they contain.
+func newAutoFixtures(t *testing.T) map[string]int {
+
+	t.Helper()
+
+	dir := t.TempDir()
+
+	feature, err := os.ReadFile("fixtures/data/136/039/131/1/1360391311.geojson")
+
+	if err != nil {
+		t.Fatalf("Failed to read feature, %v", err)
+	}
+
+	compact := new(bytes.Buffer)
+
+	err = json.Compact(compact, feature)
+
+	if err != nil {
+		t.Fatalf("Failed to compact feature, %v", err)
+	}
+
+	collection, err := os.ReadFile("fixtures/collection.geojson")
+
+	if err != nil {
+		t.Fatalf("Failed to read collection, %v", err)
+	}
+
+	pretty := new(bytes.Buffer)
+
+	err = json.Indent(pretty, collection, "", "  ")
+
+	if err != nil {
+		t.Fatalf("Failed to indent collection, %v", err)
+	}
+
+	gz := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz)
+	gz_wr.Write(feature)
+	gz_wr.Close()
+
+	files := map[string][]byte{
+		"feature.json":      feature,
+		"feature.json.gz":   gz.Bytes(),
+		"pretty.json":       append([]byte("\n\t"), pretty.Bytes()...),
+		"lines.txt":         bytes.Join([][]byte{compact.Bytes(), compact.Bytes(), compact.Bytes()}, []byte("\r\n")),
+		"array.json":        []byte("  [" + compact.String() + "," + compact.String() + "]"),
+		"single-line.jsonl": append(compact.Bytes(), '\n'),
+		"sequence.geojsons": []byte("\x1e" + compact.String() + "\n\x1e" + compact.String() + "\n"),
+	}
+
+	counts := map[string]int{
+		"feature.json":      1,
+		"feature.json.gz":   1,
+		"pretty.json":       2,
+		"lines.txt":         3,
+		"array.json":        2,
+		"single-line.jsonl": 1,
+		"sequence.geojsons": 2,
+	}
+
+	paths := make(map[string]int)
+
+	for fname, body := range files {
+
+		path := filepath.Join(dir, fname)
+
+		err := os.WriteFile(path, body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+
+		paths[path] = counts[fname]
+	}
+
+	return paths
+}
+
+func TestDetectFormat(t *testing.T) {
+
+	ctx := context.Background()
+
+	opts := &ReaderOptions{
+		Compression: "auto",
+	}
+
+	tests := map[string]string{
+		"fixtures/data":                                  "directory",
+		"testdata/archives/data.zip":                     "zip",
+		"testdata/archives/data.tar.gz":                  "tar",
+		"fixtures/data.txt":                              "filelist",
+		"fixtures/collection.geojson":                    "featurecollection",
+		"fixtures/collection.geojsonl":                   "geojsonl",
+		"fixtures/data/136/039/131/1/1360391311.geojson": "file",
+	}
+
+	for path, expected := range tests {
+
+		scheme, _, err := detectFormat(ctx, path, opts)
+
+		if err != nil {
+			t.Fatalf("Failed to detect format for %s, %v", path, err)
+		}
+
+		if scheme != expected {
+			t.Fatalf("Unexpected format for %s. Got %s but expected %s", path, scheme, expected)
+		}
+	}
+
+	empty_path := filepath.Join(t.TempDir(), "empty.json")
+
+	err := os.WriteFile(empty_path, []byte(" \n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", empty_path, err)
+	}
+
+	_, _, err = detectFormat(ctx, empty_path, opts)
+
+	if err == nil {
+		t.Fatalf("Expected empty file to fail")
+	}
+
+	// Binary data in an unrecognized format
+
+	gz := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz)
+	gz_wr.Write([]byte("fixtures/data/136/039/131/1/1360391311.geojson\n"))
+	gz_wr.Close()
+
+	binary := map[string][]byte{
+		"example.parquet": append([]byte("PAR1"), 0x15, 0x04, 0x15, 0x00, 0x00),
+		"invalid.txt":     []byte("fixtures/data\xff\xfe.geojson\n"),
+	}
+
+	for fname, body := range binary {
+
+		path := filepath.Join(t.TempDir(), fname)
+
+		err := os.WriteFile(path, body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+
+		_, _, err = detectFormat(ctx, path, opts)
+
+		if err == nil {
+			t.Fatalf("Expected binary file %s to fail", fname)
+		}
+	}
+
+	gz_path := filepath.Join(t.TempDir(), "list.txt.gz")
+
+	err = os.WriteFile(gz_path, gz.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", gz_path, err)
+	}
+
+	_, _, err = detectFormat(ctx, gz_path, &ReaderOptions{Compression: COMPRESSION_NONE})
+
+	if err == nil {
+		t.Fatalf("Expected undecompressed file to fail")
+	}
+
+	scheme, _, err := detectFormat(ctx, gz_path, opts)
+
+	if err != nil {
+		t.Fatalf("Failed to detect format for %s, %v", gz_path, err)
+	}
+
+	if scheme != "filelist" {
+		t.Fatalf("Unexpected format for %s. Got %s but expected filelist", gz_path, scheme)
+	}
+
+	_, _, err = detectFormat(ctx, STDIN, opts)
+
+	if err == nil {
+		t.Fatalf("Expected STDIN to fail")
+	}
+}
+
+func TestDetectJSONFormat(t *testing.T) {
+
+	tests := map[string]string{
+		`{"type":"Feature"}`:                               "file",
+		`{"type":"FeatureCollection","features":[]}`:       "featurecollection",
+		`{"type":"FeatureCollection"`:                      "featurecollection",
+		`{"features":[{"type":"Feature"}`:                  "featurecollection",
+		"{\"type\":\"Feature\"}\n{\"type\":\"Feature\"}":   "geojsonl",
+		"{\"type\":{\"x\":\"y\"}}\n{\"type\":\"Feature\"}": "geojsonl",
+		`{"type":{"name":"FeatureCollection"}}`:            "file",
+		`{"type":`:                                         "file",
+	}
+
+	for body, expected := range tests {
+
+		scheme := detectJSONFormat([]byte(body))
+
+		if scheme != expected {
+			t.Fatalf("Unexpected format for '%s'. Got %s but expected %s", body, scheme, expected)
+		}
+	}
+}
+
+func TestIsText(t *testing.T) {
+
+	// "é" is encoded as 0xC3 0xA9
+
+	tests := []struct {
+		body      []byte
+		truncated bool
+		expected  bool
+	}{
+		{[]byte("data/caf\xc3\xa9.geojson"), false, true},
+		{[]byte("data/caf\xc3"), true, true},
+		{[]byte("data/caf\xc3"), false, false},
+		{[]byte("data/\xc3caf"), true, false},
+		{[]byte("data/\x00"), false, false},
+	}
+
+	for _, test := range tests {
+
+		if isText(test.body, test.truncated) != test.expected {
+			t.Fatalf("Unexpected result for %q (truncated %t), expected %t", test.body, test.truncated, test.expected)
+		}
+	}
+}
+
+func TestAutoIterator(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	tests := map[string]int{
+		"fixtures/data":                 37,
+		"testdata/archives/data.zip":    37,
+		"testdata/archives/data.tar.gz": 37,
+		"fixtures/data.txt":             37,
+		"fixtures/collection.geojson":   2,
+		"fixtures/collection.geojsonl":  2,
+	}
+
+	for path, count := range newAutoFixtures(t) {
+		tests[path] = count
+	}
+
+	for path, expected := range tests {
+
+		it, err := NewIterator(ctx, "auto://")
+
+		if err != nil {
+			t.Fatalf("Failed to create new auto source, %v", err)
+		}
+
+		count := 0
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate %s, %v", path, err)
+			}
+
+			rec.Body.Close()
+			count += 1
+		}
+
+		if count != expected {
+			t.Fatalf("Unexpected record count for %s. Got %d but expected %d", path, count, expected)
+		}
+
+		err = it.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to close iterator for %s, %v", path, err)
+		}
+	}
+}
+
+func TestAutoIteratorMultiple(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Use the "raw" iterator to check that the number of records processed by each child iterator is counted
+
+	it, err := NewAutoIterator(ctx, "auto://?include=properties.wof:name=^SFO%20\\(202")
+
+	if err != nil {
+		t.Fatalf("Failed to create new auto source, %v", err)
+	}
+
+	uris := []string{
+		"testdata/archives/data.zip",
+		"fixtures/data.txt",
+		"fixtures/collection.geojson",
+	}
+
+	count := 0
+
+	for rec, err := range it.Iterate(ctx, uris...) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+		count += 1
+	}
+
+	if count != 6 {
+		t.Fatalf("Unexpected record count. Got %d but expected 6", count)
+	}
+
+	seen := it.Seen()
+
+	if seen != 76 {
+		t.Fatalf("Unexpected seen count. Got %d but expected 76", seen)
+	}
+}
@@ -0,0 +1,497 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	gc "gocloud.dev/blob"
+)
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "blob", NewBlobIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// BlobIterator implements the `Iterator` interface for crawling records stored in a `gocloud.dev/blob` bucket.
+type BlobIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// prefix is an optional prefix used to limit the objects that are crawled.
+	prefix string
+	// page_size is the number of objects to retrieve with each listing request.
+	page_size int
+	// max_procs is the maximum number of objects to process simultaneously.
+	max_procs int
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewBlobIterator() returns a new `BlobIterator` instance configured by 'uri' in the form of:
+//
+//	blob://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?prefix=` An optional prefix used to limit the objects that are crawled.
+// * `?page_size=` The number of objects to retrieve with each listing request. (Default is 1000.)
+// * `?_max_procs=` The maximum number of objects to process simultaneously. (Default is the value of `runtime.NumCPU()`.)
+//
+// URIs passed to the `Iterate` method are expected to be valid `gocloud.dev/blob` bucket URIs (for example
+// "s3://example-bucket?region=us-east-1"). The corresponding `gocloud.dev/blob` driver must be imported
+// by the calling code. Record bodies are not fetched until they are first read (or the iterator's filters
+// are applied) and the size and modification time of each object are available via the record's `Info` property.
+func NewBlobIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	page_size := 1000
+	max_procs := runtime.NumCPU()
+
+	if q.Has("page_size") {
+
+		v, err := strconv.Atoi(q.Get("page_size"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'page_size' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid 'page_size' parameter, must be greater than zero")
+		}
+
+		page_size = v
+	}
+
+	if q.Has("_max_procs") {
+
+		v, err := strconv.Atoi(q.Get("_max_procs"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '_max_procs' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid '_max_procs' parameter, must be greater than zero")
+		}
+
+		max_procs = v
+	}
+
+	it := &BlobIterator{
+		filters:   f,
+		prefix:    q.Get("prefix"),
+		page_size: page_size,
+		max_procs: max_procs,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *BlobIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateBucket(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateBucket yields records for each object in the bucket at 'uri'. It returns false if 'yield' has
+// signaled that iteration should stop.
+func (it *BlobIterator) iterateBucket(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger_uri, err := iterate.ScrubURI(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to scrub URI, %w", err))
+	}
+
+	logger := slog.Default()
+	logger = logger.With("uri", logger_uri)
+
+	b, err := gc.OpenBucket(ctx, uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open bucket '%s', %w", logger_uri, err))
+	}
+
+	// The bucket remains open until both this method and all the record bodies it produced have been closed.
+	// Record bodies are read using the parent context since they may be read after this method has returned.
+
+	bucket := newBucketRef(b)
+	defer bucket.release()
+
+	body_ctx := ctx
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	obj_ch := make(chan *gc.ListObject)
+	rec_ch := make(chan *iterate.Record)
+	err_ch := make(chan error)
+
+	go func() {
+
+		defer close(obj_ch)
+
+		opts := &gc.ListOptions{
+			Prefix: it.prefix,
+		}
+
+		token := gc.FirstPageToken
+
+		for {
+
+			objects, next, err := b.ListPage(ctx, token, it.page_size, opts)
+
+			if err != nil {
+
+				select {
+				case <-ctx.Done():
+				case err_ch <- fmt.Errorf("Failed to list objects in '%s', %w", logger_uri, err):
+				}
+
+				return
+			}
+
+			logger.Debug("List objects", "count", len(objects))
+
+			for _, obj := range objects {
+
+				if obj.IsDir {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case obj_ch <- obj:
+					// pass
+				}
+			}
+
+			if len(next) == 0 {
+				return
+			}
+
+			token = next
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < it.max_procs; i++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for obj := range obj_ch {
+
+				logger.Debug("Process object", "key", obj.Key)
+
+				rec, err := it.processObject(ctx, body_ctx, bucket, obj)
+
+				if err != nil {
+
+					select {
+					case <-ctx.Done():
+						return
+					case err_ch <- err:
+						continue
+					}
+				}
+
+				if rec == nil {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					rec.Body.Close()
+					return
+				case rec_ch <- rec:
+					// pass
+				}
+			}
+		}()
+	}
+
+	done_ch := make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done_ch)
+	}()
+
+	for {
+		select {
+		case <-done_ch:
+			return true
+		case err := <-err_ch:
+			if !yield(nil, err) {
+				return false
+			}
+		case rec := <-rec_ch:
+			if !yield(rec, nil) {
+				return false
+			}
+		}
+	}
+}
+
+// processObject returns a new `Record` instance for 'obj' or nil if the record has been excluded by the iterator's filters.
+func (it *BlobIterator) processObject(ctx context.Context, body_ctx context.Context, bucket *bucketRef, obj *gc.ListObject) (*iterate.Record, error) {
+
+	atomic.AddInt64(&it.seen, 1)
+
+	r := newBlobReader(body_ctx, bucket, obj)
+
+	if it.filters != nil {
+
+		ok, err := iterate.ApplyFilters(ctx, r, it.filters)
+
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("Failed to apply filters for '%s', %w", obj.Key, err)
+		}
+
+		if !ok {
+			r.Close()
+			return nil, nil
+		}
+	}
+
+	rec := iterate.NewRecord(obj.Key, r)
+	rec.Info = &blobFileInfo{obj}
+
+	return rec, nil
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *BlobIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *BlobIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *BlobIterator) Close() error {
+	return nil
+}
+
+// bucketRef is a reference-counted wrapper around a `gocloud.dev/blob.Bucket` instance.
+type bucketRef struct {
+	bucket *gc.Bucket
+	refs   int64
+}
+
+func newBucketRef(b *gc.Bucket) *bucketRef {
+
+	r := &bucketRef{
+		bucket: b,
+		refs:   int64(1),
+	}
+
+	return r
+}
+
+func (r *bucketRef) retain() {
+	atomic.AddInt64(&r.refs, 1)
+}
+
+func (r *bucketRef) release() error {
+
+	if atomic.AddInt64(&r.refs, -1) == 0 {
+		return r.bucket.Close()
+	}
+
+	return nil
+}
+
+// blobReader implements the `io.ReadSeekCloser` interface for an object in a bucket. The object is not fetched
+// until it is first read and seeking is accomplished by issuing a new range request starting at the desired offset.
+type blobReader struct {
+	ctx    context.Context
+	bucket *bucketRef
+	key    string
+	size   int64
+	reader *gc.Reader
+	offset int64
+	closed bool
+}
+
+func newBlobReader(ctx context.Context, bucket *bucketRef, obj *gc.ListObject) *blobReader {
+
+	bucket.retain()
+
+	r := &blobReader{
+		ctx:    ctx,
+		bucket: bucket,
+		key:    obj.Key,
+		size:   obj.Size,
+	}
+
+	return r
+}
+
+// Read implements the `io.Reader` interface.
+func (r *blobReader) Read(p []byte) (int, error) {
+
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if r.reader == nil {
+
+		if r.offset >= r.size {
+			return 0, io.EOF
+		}
+
+		br, err := r.bucket.bucket.NewRangeReader(r.ctx, r.key, r.offset, -1, nil)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to open '%s', %w", r.key, err)
+		}
+
+		r.reader = br
+	}
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+// Seek implements the `io.Seeker` interface.
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, fmt.Errorf("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, fmt.Errorf("Negative position")
+	}
+
+	if abs == r.offset {
+		return abs, nil
+	}
+
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// Close implements the `io.Closer` interface.
+func (r *blobReader) Close() error {
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+
+	return r.bucket.release()
+}
+
+// blobFileInfo implements the `fs.FileInfo` interface for an object in a bucket.
+type blobFileInfo struct {
+	obj *gc.ListObject
+}
+
+func (i *blobFileInfo) Name() string {
+	return path.Base(i.obj.Key)
+}
+
+func (i *blobFileInfo) Size() int64 {
+	return i.obj.Size
+}
+
+func (i *blobFileInfo) Mode() fs.FileMode {
+	return 0444
+}
+
+func (i *blobFileInfo) ModTime() time.Time {
+	return i.obj.ModTime
+}
+
+func (i *blobFileInfo) IsDir() bool {
+	return false
+}
+
+func (i *blobFileInfo) Sys() any {
+	return i.obj
+}
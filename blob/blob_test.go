@@ -0,0 +1,168 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	gc "gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+	"gocloud.dev/blob/memblob"
+)
+
+// memTestOpener implements the `gocloud.dev/blob.BucketURLOpener` interface returning a new `memblob` bucket
+// populated with the contents of the "fixtures/data" directory, since "mem://" buckets are always empty when opened.
+type memTestOpener struct{}
+
+func (o *memTestOpener) OpenBucketURL(ctx context.Context, u *url.URL) (*gc.Bucket, error) {
+
+	b := memblob.OpenBucket(nil)
+
+	root := "../fixtures/data"
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		rel_path, err := filepath.Rel(root, path)
+
+		if err != nil {
+			return err
+		}
+
+		return b.WriteAll(ctx, "data/"+filepath.ToSlash(rel_path), body, nil)
+	})
+
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	err = b.WriteAll(ctx, "README.md", []byte("# Hello world"), nil)
+
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func init() {
+	gc.DefaultURLMux().RegisterBucket("memtest", &memTestOpener{})
+}
+
+func TestBlobIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	abs_path, err := filepath.Abs("../fixtures/data")
+
+	if err != nil {
+		t.Fatalf("Failed to derive absolute path for fixtures, %v", err)
+	}
+
+	tests := map[string]int64{
+		"blob://":                          37,
+		"blob://?page_size=5&_max_procs=2": 37,
+		"blob://?prefix=147/":              10,
+		"blob://?exclude=properties.wof:placetype=custom": 0,
+	}
+
+	for iter_uri, expected := range tests {
+
+		it, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new blob source for '%s', %v", iter_uri, err)
+		}
+
+		bucket_uri := fmt.Sprintf("file://%s", abs_path)
+
+		for rec, err := range it.Iterate(ctx, bucket_uri) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' with '%s', %v", bucket_uri, iter_uri, err)
+			}
+
+			defer rec.Body.Close()
+
+			body, err := io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			if int64(len(body)) != rec.Info.Size() {
+				t.Fatalf("Unexpected body length for %s. Got %d but expected %d", rec.Path, len(body), rec.Info.Size())
+			}
+
+			_, err = rec.Body.Seek(0, 0)
+
+			if err != nil {
+				t.Fatalf("Failed to rewind body for %s, %v", rec.Path, err)
+			}
+
+			_, err = io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed second read body for %s, %v", rec.Path, err)
+			}
+		}
+
+		seen := it.Seen()
+
+		if seen != expected {
+			t.Fatalf("Unexpected record count for '%s'. Got %d but expected %d", iter_uri, seen, expected)
+		}
+	}
+}
+
+func TestBlobIteratorMemBlob(t *testing.T) {
+
+	ctx := context.Background()
+
+	it, err := iterate.NewIterator(ctx, "blob://?prefix=data/&_max_procs=4")
+
+	if err != nil {
+		t.Fatalf("Failed to create new blob source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, "memtest://") {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		if !strings.HasPrefix(rec.Path, "data/") {
+			t.Fatalf("Unexpected path %s", rec.Path)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(37)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+}
@@ -0,0 +1,25 @@
+// Package blob provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling records stored in a `gocloud.dev/blob` bucket. The package registers itself with the "blob" scheme
+// so it needs to be imported alongside any of the `gocloud.dev/blob` drivers (for example `gocloud.dev/blob/s3blob`)
+// that will be used to open buckets. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/blob"
+//		_ "gocloud.dev/blob/s3blob"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "blob://?prefix=data/")
+//
+//		for rec, _ := range it.Iterate(ctx, "s3://example-bucket?region=us-east-1") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+package blob
@@ -0,0 +1,263 @@
+package iterate
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// COMPRESSION_AUTO signals that the compression scheme for a file should be derived from its contents or file extension.
+const COMPRESSION_AUTO string = "auto"
+
+// COMPRESSION_NONE signals that a file should be read as-is.
+const COMPRESSION_NONE string = "none"
+
+// COMPRESSION_GZIP signals that a file is compressed using gzip.
+const COMPRESSION_GZIP string = "gzip"
+
+// COMPRESSION_BZIP2 signals that a file is compressed using bzip2.
+const COMPRESSION_BZIP2 string = "bzip2"
+
+// COMPRESSION_ZSTD signals that a file is compressed using zstd.
+const COMPRESSION_ZSTD string = "zstd"
+
+var magic_gzip = []byte{0x1f, 0x8b}
+var magic_bzip2 = []byte("BZh")
+var magic_zstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// compressionFromQuery returns the value of the `?compression=` parameter in 'q' defaulting to `COMPRESSION_AUTO`.
+func compressionFromQuery(q url.Values) (string, error) {
+
+	if !q.Has("compression") {
+		return COMPRESSION_AUTO, nil
+	}
+
+	compression := strings.ToLower(q.Get("compression"))
+
+	switch compression {
+	case COMPRESSION_AUTO, COMPRESSION_NONE, COMPRESSION_GZIP, COMPRESSION_BZIP2, COMPRESSION_ZSTD:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("Invalid or unsupported compression '%s'", compression)
+	}
+}
+
+// detectCompression derives the compression scheme for 'r' from its leading "magic" bytes and, if those
+// can not be read, the file extension of 'path'. 'r' is rewound before returning.
+func detectCompression(r io.ReadSeeker, path string) (string, error) {
+
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(r, magic)
+
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("Failed to read magic bytes, %w", err)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to rewind reader, %w", err)
+	}
+
+	magic = magic[0:n]
+
+	switch {
+	case bytes.HasPrefix(magic, magic_gzip):
+		return COMPRESSION_GZIP, nil
+	case bytes.HasPrefix(magic, magic_bzip2):
+		return COMPRESSION_BZIP2, nil
+	case bytes.HasPrefix(magic, magic_zstd):
+		return COMPRESSION_ZSTD, nil
+	}
+
+	if n == len(magic_zstd) {
+		return COMPRESSION_NONE, nil
+	}
+
+	return compressionFromExtension(path), nil
+}
+
+// compressionFromExtension derives the compression scheme for 'path' from its file extension.
+func compressionFromExtension(path string) string {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".tgz":
+		return COMPRESSION_GZIP
+	case ".bz2", ".tbz", ".tbz2":
+		return COMPRESSION_BZIP2
+	case ".zst":
+		return COMPRESSION_ZSTD
+	default:
+		return COMPRESSION_NONE
+	}
+}
+
+// decompressReader implements the `io.ReadSeekCloser` interface for reading decompressed data from an
+// underlying compressed `io.ReadSeekCloser` instance. Seeking is accomplished by rewinding the underlying
+// reader (as necessary) and discarding decompressed bytes until the desired offset is reached. This means
+// that rewinding a reader is cheap but arbitrary seeks are proportional to the size of the data being skipped.
+type decompressReader struct {
+	source      io.ReadSeekCloser
+	compression string
+	reader      io.Reader
+	offset      int64
+	dirty       bool
+}
+
+// newDecompressReader returns a new `decompressReader` instance for reading 'source' decompressed using 'compression'.
+func newDecompressReader(source io.ReadSeekCloser, compression string) (*decompressReader, error) {
+
+	r := &decompressReader{
+		source:      source,
+		compression: compression,
+	}
+
+	err := r.reset()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Read implements the `io.Reader` interface.
+func (r *decompressReader) Read(p []byte) (int, error) {
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+// Seek implements the `io.Seeker` interface.
+func (r *decompressReader) Seek(offset int64, whence int) (int64, error) {
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+
+		// The uncompressed size is not known until the end of the stream is reached.
+
+		_, err := io.Copy(io.Discard, r)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to read to end of stream, %w", err)
+		}
+
+		abs = r.offset + offset
+
+	default:
+		return 0, fmt.Errorf("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, fmt.Errorf("Negative position")
+	}
+
+	if abs < r.offset {
+
+		err := r.reset()
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if abs > r.offset {
+
+		_, err := io.CopyN(io.Discard, r, abs-r.offset)
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("Failed to advance to offset %d, %w", abs, err)
+		}
+	}
+
+	return r.offset, nil
+}
+
+// Close implements the `io.Closer` interface.
+func (r *decompressReader) Close() error {
+
+	r.closeReader()
+	return r.source.Close()
+}
+
+// reset rewinds the underlying reader, if necessary, and creates a new decompressor.
+func (r *decompressReader) reset() error {
+
+	r.closeReader()
+
+	if r.dirty {
+
+		_, err := r.source.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return fmt.Errorf("Failed to rewind source, %w", err)
+		}
+	}
+
+	var dr io.Reader
+
+	switch r.compression {
+	case COMPRESSION_GZIP:
+
+		gz_r, err := gzip.NewReader(r.source)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create gzip reader, %w", err)
+		}
+
+		dr = gz_r
+
+	case COMPRESSION_BZIP2:
+		dr = bzip2.NewReader(r.source)
+	case COMPRESSION_ZSTD:
+
+		zstd_r, err := zstd.NewReader(r.source, zstd.WithDecoderConcurrency(1))
+
+		if err != nil {
+			return fmt.Errorf("Failed to create zstd reader, %w", err)
+		}
+
+		dr = zstd_r
+
+	default:
+		return fmt.Errorf("Invalid or unsupported compression '%s'", r.compression)
+	}
+
+	r.reader = dr
+	r.offset = 0
+
+	// Creating the gzip reader will have consumed the header so the source always
+	// needs to be rewound the next time the reader is reset.
+	r.dirty = true
+
+	return nil
+}
+
+// closeReader releases any resources associated with the current decompressor.
+func (r *decompressReader) closeReader() {
+
+	switch dr := r.reader.(type) {
+	case *gzip.Reader:
+		dr.Close()
+	case *zstd.Decoder:
+		dr.Close()
+	}
+
+	r.reader = nil
+}
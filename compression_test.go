@@ -0,0 +1,202 @@
+package iterate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestReaderWithPathCompression(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	root := t.TempDir()
+
+	for _, path := range writeCompressedFixtures(t, root, raw) {
+
+		r, err := ReaderWithPath(ctx, path)
+
+		if err != nil {
+			t.Fatalf("Failed to create reader for %s, %v", path, err)
+		}
+
+		defer r.Close()
+
+		body, err := io.ReadAll(r)
+
+		if err != nil {
+			t.Fatalf("Failed to read %s, %v", path, err)
+		}
+
+		if !bytes.Equal(body, raw) {
+			t.Fatalf("Unexpected body for %s", path)
+		}
+
+		_, err = r.Seek(0, io.SeekStart)
+
+		if err != nil {
+			t.Fatalf("Failed to rewind %s, %v", path, err)
+		}
+
+		body, err = io.ReadAll(r)
+
+		if err != nil {
+			t.Fatalf("Failed second read of %s, %v", path, err)
+		}
+
+		if !bytes.Equal(body, raw) {
+			t.Fatalf("Unexpected body for %s after rewinding", path)
+		}
+
+		offset, err := r.Seek(100, io.SeekStart)
+
+		if err != nil {
+			t.Fatalf("Failed to seek %s, %v", path, err)
+		}
+
+		body, err = io.ReadAll(r)
+
+		if err != nil {
+			t.Fatalf("Failed read of %s after seeking, %v", path, err)
+		}
+
+		if offset != 100 || !bytes.Equal(body, raw[100:]) {
+			t.Fatalf("Unexpected body for %s after seeking", path)
+		}
+
+		size, err := r.Seek(0, io.SeekEnd)
+
+		if err != nil {
+			t.Fatalf("Failed to seek to end of %s, %v", path, err)
+		}
+
+		if size != int64(len(raw)) {
+			t.Fatalf("Unexpected size for %s. Got %d but expected %d", path, size, len(raw))
+		}
+	}
+
+	gz_path := filepath.Join(root, "collection.geojsonl.gz")
+
+	r, err := ReaderWithPathAndCompression(ctx, gz_path, COMPRESSION_NONE)
+
+	if err != nil {
+		t.Fatalf("Failed to create reader for %s, %v", gz_path, err)
+	}
+
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Failed to read %s, %v", gz_path, err)
+	}
+
+	if !bytes.HasPrefix(body, magic_gzip) {
+		t.Fatalf("Expected %s to be read without decompression", gz_path)
+	}
+}
+
+func TestGeoJSONLIteratorCompression(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	paths := writeCompressedFixtures(t, t.TempDir(), raw)
+
+	it, err := NewIterator(ctx, "geojsonl://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, paths...) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(2 * len(paths))
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+
+	_, err = NewIterator(ctx, "geojsonl://?compression=lz4")
+
+	if err == nil {
+		t.Fatalf("Expected invalid compression to fail")
+	}
+}
+
+// writeCompressedFixtures writes gzip and zstd compressed copies of 'raw' to 'root' and returns their paths.
+func writeCompressedFixtures(t *testing.T, root string, raw []byte) []string {
+
+	gz_path := filepath.Join(root, "collection.geojsonl.gz")
+	gz_buf := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz_buf)
+
+	_, err := gz_wr.Write(raw)
+
+	if err != nil {
+		t.Fatalf("Failed to write gzip data, %v", err)
+	}
+
+	err = gz_wr.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close gzip writer, %v", err)
+	}
+
+	// Note the absence of a file extension; compression should be derived from the magic bytes.
+	zst_path := filepath.Join(root, "collection")
+	zst_buf := new(bytes.Buffer)
+	zst_wr, err := zstd.NewWriter(zst_buf)
+
+	if err != nil {
+		t.Fatalf("Failed to create zstd writer, %v", err)
+	}
+
+	_, err = zst_wr.Write(raw)
+
+	if err != nil {
+		t.Fatalf("Failed to write zstd data, %v", err)
+	}
+
+	err = zst_wr.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close zstd writer, %v", err)
+	}
+
+	for path, buf := range map[string]*bytes.Buffer{gz_path: gz_buf, zst_path: zst_buf} {
+
+		err := os.WriteFile(path, buf.Bytes(), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+	}
+
+	return []string{gz_path, zst_path}
+}
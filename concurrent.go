@@ -369,6 +369,13 @@ func (it *concurrentIterator) Iterate(ctx context.Context, uris ...string) iter.
 
 					for rec, err := range it.iterator.Iterate(ctx, target_uri) {
 
+						// Records which can not be found are reported but do not stop iteration
+						if IsNotFound(err) {
+							logger.Warn("Record not found", "error", err)
+							err_ch <- err
+							continue
+						}
+
 						if err != nil {
 							logger.Error("Iterator failed", "counter", atomic.LoadInt64(&it_counter), "local counter", atomic.LoadInt64(&local_counter), "error", err)
 							return err
@@ -443,8 +450,6 @@ func (it *concurrentIterator) Iterate(ctx context.Context, uris ...string) iter.
 				if !yield(rec, nil) {
 					return
 				}
-			default:
-				// pass
 			}
 		}
 
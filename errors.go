@@ -0,0 +1,38 @@
+package iterate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NotFoundError is an error signaling that a record could not be found. Iterators yield this error, rather than
+// stopping, when a requested record does not exist and iteration continues with the next record.
+type NotFoundError struct {
+	// Id is the Who's On First ID of the record that could not be found.
+	Id int64
+	// AltLabel is the alternate geometry label of the record that could not be found, if any.
+	AltLabel string
+	// Source is the URI (and line number) where the record was requested.
+	Source string
+	// Roots are the locations that were searched for the record.
+	Roots []string
+}
+
+// Error returns a string describing the record that could not be found.
+func (e *NotFoundError) Error() string {
+
+	label := fmt.Sprintf("%d", e.Id)
+
+	if e.AltLabel != "" {
+		label = fmt.Sprintf("%d (%s)", e.Id, e.AltLabel)
+	}
+
+	return fmt.Sprintf("Record %s, requested by '%s', not found in %s", label, e.Source, strings.Join(e.Roots, ", "))
+}
+
+// IsNotFound returns a boolean value indicating whether 'err' is, or wraps, a `NotFoundError`.
+func IsNotFound(err error) bool {
+	var nf *NotFoundError
+	return errors.As(err, &nf)
+}
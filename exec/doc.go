@@ -0,0 +1,27 @@
+// Package exec provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling features written, as line-separated GeoJSON, to the standard output of a command. The package registers
+// itself with the "exec" scheme so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/exec"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "exec://")
+//
+//		for rec, _ := range it.Iterate(ctx, "ogr2ogr -f GeoJSONSeq -lco RS=NO /vsistdout/ /usr/local/data/example.shp") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+//
+// Importing this package allows any iterator URI, and any URI passed to the `Iterate` method, to run commands. This
+// includes the child iterator URIs of composite iterators like `multi://` and `overlay://`. Do not import it in
+// programs that accept iterator URIs from untrusted sources.
+package exec
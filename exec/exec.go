@@ -0,0 +1,308 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	os_exec "os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+// EXEC_STDERR_MAX is the maximum number of bytes of a command's standard error output, from the end, included in errors.
+const EXEC_STDERR_MAX int = 4096
+
+// EXEC_WAIT_DELAY is the amount of time to wait for a command's output to be closed after it has exited or been killed.
+const EXEC_WAIT_DELAY time.Duration = 1 * time.Second
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "exec", NewExecIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ExecIterator implements the `Iterator` interface for crawling features written, as line-separated GeoJSON, to
+// the standard output of a command.
+type ExecIterator struct {
+	iterate.Iterator
+	// parser is the `iterate.GeoJSONLIterator` instance used to parse the output of each command.
+	parser *iterate.GeoJSONLIterator
+	// shell is a boolean value indicating whether commands are run using "sh -c".
+	shell bool
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewExecIterator() returns a new `ExecIterator` instance configured by 'uri' in the form of:
+//
+//	exec://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?shell=` A boolean value indicating whether commands should be run using "sh -c", allowing the use of pipes, redirects and variables. (Default is false.)
+//
+// Each URI passed to the `Iterate` method is a command line. Unless `?shell=` is true it is split in to arguments, honouring
+// single quotes, double quotes and backslash escapes, and run directly. The `Path` property of each record is the command line
+// followed by "#" and the (zero-based) line number of the record in the command's output.
+func NewExecIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	shell := false
+
+	if q.Has("shell") {
+
+		v, err := strconv.ParseBool(q.Get("shell"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'shell' parameter, %w", err)
+		}
+
+		shell = v
+	}
+
+	// Only the filtering parameters are passed to the parser since the other `geojsonl://` parameters apply to files
+
+	parser_q := url.Values{}
+
+	for _, k := range []string{"include", "exclude", "include_mode", "exclude_mode"} {
+
+		if q.Has(k) {
+			parser_q[k] = q[k]
+		}
+	}
+
+	parser, err := iterate.NewGeoJSONLIterator(ctx, fmt.Sprintf("geojsonl://?%s", parser_q.Encode()))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create output parser, %w", err)
+	}
+
+	it := &ExecIterator{
+		parser:    parser.(*iterate.GeoJSONLIterator),
+		shell:     shell,
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*iterate.Record, error]` for each record written to the standard output of the commands
+// defined by 'uris'. If a command exits with a non-zero status an error, including the end of its standard error output,
+// is yielded after its records. Commands are killed if the context is cancelled or iteration is stopped early.
+func (it *ExecIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateCommand(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateCommand runs the command line 'uri' and yields a record for each line of its standard output. It returns
+// false if iteration should stop.
+func (it *ExecIterator) iterateCommand(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	// Cancelling the context when this function returns ensures the command is killed if the consumer stops early
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var args []string
+
+	if it.shell {
+		args = []string{"sh", "-c", uri}
+	} else {
+
+		v, err := splitCommandLine(uri)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to parse command line '%s', %w", uri, err))
+		}
+
+		if len(v) == 0 {
+			return yield(nil, fmt.Errorf("Empty command line"))
+		}
+
+		args = v
+	}
+
+	stderr := &tailBuffer{max: EXEC_STDERR_MAX}
+
+	cmd := os_exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = stderr
+	cmd.WaitDelay = EXEC_WAIT_DELAY
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create output pipe for '%s', %w", uri, err))
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to start '%s', %w", uri, err))
+	}
+
+	ok := true
+
+	for rec, err := range it.parser.IterateReader(ctx, uri, stdout) {
+
+		if !yield(rec, err) {
+			ok = false
+			break
+		}
+	}
+
+	if !ok {
+		cancel()
+		cmd.Wait()
+		return false
+	}
+
+	err = cmd.Wait()
+
+	// The command was killed because the context was cancelled
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+
+		msg := strings.TrimSpace(stderr.String())
+
+		if msg != "" {
+			return yield(nil, fmt.Errorf("Failed to run '%s', %w: %s", uri, err, msg))
+		}
+
+		return yield(nil, fmt.Errorf("Failed to run '%s', %w", uri, err))
+	}
+
+	return true
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *ExecIterator) Seen() int64 {
+	return it.parser.Seen()
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *ExecIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *ExecIterator) Close() error {
+	return nil
+}
+
+// tailBuffer implements the `io.Writer` interface retaining (at most) the last 'max' bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+// Write appends 'p' to the buffer discarding the oldest bytes if its maximum size is exceeded.
+func (b *tailBuffer) Write(p []byte) (int, error) {
+
+	b.buf = append(b.buf, p...)
+
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+
+	return len(p), nil
+}
+
+// String returns the contents of the buffer.
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// splitCommandLine splits 'cmdline' in to arguments separated by whitespace. Single quotes preserve their contents
+// literally; inside double quotes, and outside of quotes, a backslash escapes the following character.
+func splitCommandLine(cmdline string) ([]string, error) {
+
+	args := make([]string, 0)
+
+	var arg strings.Builder
+
+	in_arg := false
+	quote := rune(0)
+	escaped := false
+
+	for _, c := range cmdline {
+
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+			in_arg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			in_arg = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if in_arg {
+				args = append(args, arg.String())
+				arg.Reset()
+				in_arg = false
+			}
+		default:
+			arg.WriteRune(c)
+			in_arg = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("Trailing backslash")
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+
+	if in_arg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,220 @@
+package exec
+
+import (
+	"context"
+	"io"
+	os_exec "os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+func TestExecIterator(t *testing.T) {
+
+	_, err := os_exec.LookPath("sh")
+
+	if err != nil {
+		t.Skip("Missing sh command")
+	}
+
+	ctx := context.Background()
+
+	it, err := iterate.NewIterator(ctx, "exec://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new exec source, %v", err)
+	}
+
+	count := 0
+
+	for rec, err := range it.Iterate(ctx, "cat ../fixtures/collection.geojsonl") {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate command, %v", err)
+		}
+
+		_, err = io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		count += 1
+	}
+
+	if count != 2 {
+		t.Fatalf("Unexpected record count. Got %d but expected 2", count)
+	}
+}
+
+func TestExecIteratorFailure(t *testing.T) {
+
+	_, err := os_exec.LookPath("sh")
+
+	if err != nil {
+		t.Skip("Missing sh command")
+	}
+
+	ctx := context.Background()
+
+	it, err := NewExecIterator(ctx, "exec://?shell=true")
+
+	if err != nil {
+		t.Fatalf("Failed to create new exec source, %v", err)
+	}
+
+	count := 0
+	errs := make([]error, 0)
+
+	for rec, err := range it.Iterate(ctx, "cat ../fixtures/collection.geojsonl; echo 'something went wrong' >&2; exit 3") {
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		rec.Body.Close()
+		count += 1
+	}
+
+	if count != 2 {
+		t.Fatalf("Unexpected record count. Got %d but expected 2", count)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Unexpected error count. Got %d but expected 1", len(errs))
+	}
+
+	msg := errs[0].Error()
+
+	if !strings.Contains(msg, "exit status 3") || !strings.Contains(msg, "something went wrong") {
+		t.Fatalf("Unexpected error, %s", msg)
+	}
+}
+
+func TestExecIteratorStop(t *testing.T) {
+
+	_, err := os_exec.LookPath("sh")
+
+	if err != nil {
+		t.Skip("Missing sh command")
+	}
+
+	ctx := context.Background()
+
+	it, err := NewExecIterator(ctx, "exec://?shell=true")
+
+	if err != nil {
+		t.Fatalf("Failed to create new exec source, %v", err)
+	}
+
+	// This command never exits on its own
+
+	cmd := "while true; do cat ../fixtures/collection.geojsonl; echo; done"
+
+	// Stopping early
+
+	done_ch := make(chan bool)
+
+	go func() {
+
+		defer close(done_ch)
+
+		count := 0
+
+		for rec, err := range it.Iterate(ctx, cmd) {
+
+			if err != nil {
+				t.Errorf("Failed to iterate command, %v", err)
+				return
+			}
+
+			rec.Body.Close()
+			count += 1
+
+			if count == 5 {
+				break
+			}
+		}
+	}()
+
+	select {
+	case <-done_ch:
+		// pass
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for iteration to stop")
+	}
+
+	// Cancelling the context
+
+	cancel_ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done_ch = make(chan bool)
+
+	go func() {
+
+		defer close(done_ch)
+
+		count := 0
+
+		for rec, err := range it.Iterate(cancel_ctx, cmd) {
+
+			if err != nil {
+				t.Errorf("Failed to iterate command, %v", err)
+				return
+			}
+
+			rec.Body.Close()
+			count += 1
+
+			if count == 5 {
+				cancel()
+			}
+		}
+	}()
+
+	select {
+	case <-done_ch:
+		// pass
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for iteration to be cancelled")
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+
+	tests := map[string][]string{
+		"":                                {},
+		"cat a.geojsonl":                  {"cat", "a.geojsonl"},
+		"  ogr2ogr   -f GeoJSONSeq  ":     {"ogr2ogr", "-f", "GeoJSONSeq"},
+		`ogr2ogr -where "name = 'SFO'" x`: {"ogr2ogr", "-where", "name = 'SFO'", "x"},
+		`echo 'a "b" \c' d\ e ""`:         {"echo", `a "b" \c`, "d e", ""},
+		`python3 -c "print(\"hello\")"`:   {"python3", "-c", `print("hello")`},
+	}
+
+	for cmdline, expected := range tests {
+
+		args, err := splitCommandLine(cmdline)
+
+		if err != nil {
+			t.Fatalf("Failed to split '%s', %v", cmdline, err)
+		}
+
+		if !slices.Equal(args, expected) {
+			t.Fatalf("Unexpected arguments for '%s'. Got %q but expected %q", cmdline, args, expected)
+		}
+	}
+
+	for _, cmdline := range []string{`echo "a`, `echo 'a`, `echo a\`} {
+
+		_, err := splitCommandLine(cmdline)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", cmdline)
+		}
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"iter"
+	"net/url"
 	"sync/atomic"
 
 	"github.com/whosonfirst/go-ioutil"
@@ -27,6 +27,8 @@ type FeatureCollectionIterator struct {
 	Iterator
 	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
 	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
 	// seen is the count of documents that have been processed.
 	seen int64
 	// iterating is a boolean value indicating whether records are still being iterated.
@@ -42,18 +44,37 @@ type FeatureCollectionIterator struct {
 // * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
 // * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
 // * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each file. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
 func NewFeatureCollectionIterator(ctx context.Context, uri string) (Iterator, error) {
 
-	f, err := filters.NewQueryFiltersFromURI(ctx, uri)
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
 	}
 
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
 	i := &FeatureCollectionIterator{
-		filters:   f,
-		seen:      int64(0),
-		iterating: new(atomic.Bool),
+		filters:        f,
+		reader_options: reader_options,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
 	}
 
 	return i, nil
@@ -69,7 +90,7 @@ func (it *FeatureCollectionIterator) Iterate(ctx context.Context, uris ...string
 
 		for _, uri := range uris {
 
-			r, err := ReaderWithPath(ctx, uri)
+			r, err := ReaderWithPathAndOptions(ctx, uri, it.reader_options)
 
 			if err != nil {
 				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err)) {
@@ -81,55 +102,50 @@ func (it *FeatureCollectionIterator) Iterate(ctx context.Context, uris ...string
 
 			defer r.Close()
 
-			body, err := io.ReadAll(r)
+			// Features are decoded one at a time, as raw bytes, so that memory use is bounded by the size of
+			// the largest feature rather than the size of the collection and so that the original encoding
+			// (key order, numeric precision, etc.) of each feature is preserved.
 
-			if err != nil {
+			dec := json.NewDecoder(r)
 
-				if !yield(nil, fmt.Errorf("Failed to read body for '%s', %w", uri, err)) {
-					return
-				}
-
-				continue
-			}
-
-			type FC struct {
-				Type     string
-				Features []interface{}
-			}
-
-			var collection FC
-
-			err = json.Unmarshal(body, &collection)
+			err = seekFeatures(dec)
 
 			if err != nil {
-				if !yield(nil, fmt.Errorf("Failed to unmarshal '%s' as a feature collection, %w", uri, err)) {
+				if !yield(nil, fmt.Errorf("Failed to read '%s' as a feature collection, %w", uri, err)) {
 					return
 				}
 
 				continue
 			}
 
-			for i, f := range collection.Features {
+			for i := 0; dec.More(); i++ {
 
 				select {
 				case <-ctx.Done():
-					break
+					return
 				default:
 					// pass
 				}
 
 				path := fmt.Sprintf("%s#%d", uri, i)
 
-				feature, err := json.Marshal(f)
+				var feature json.RawMessage
+
+				err := dec.Decode(&feature)
 
 				if err != nil {
-					if !yield(nil, fmt.Errorf("Failed to marshal feature for '%s', %w", path, err)) {
+
+					// It's not possible to recover from a decoding error so move on to the next URI
+
+					if !yield(nil, fmt.Errorf("Failed to decode feature for '%s', %w", path, err)) {
 						return
 					}
 
-					continue
+					break
 				}
 
+				atomic.AddInt64(&it.seen, 1)
+
 				br := bytes.NewReader(feature)
 				rsc, err := ioutil.NewReadSeekCloser(br)
 
@@ -171,6 +187,63 @@ func (it *FeatureCollectionIterator) Iterate(ctx context.Context, uris ...string
 	}
 }
 
+// seekFeatures advances 'dec' to the first element of the top-level "features" array in a GeoJSON FeatureCollection.
+// Any other top-level properties encountered before the "features" array are skipped.
+func seekFeatures(dec *json.Decoder) error {
+
+	t, err := dec.Token()
+
+	if err != nil {
+		return fmt.Errorf("Failed to read opening token, %w", err)
+	}
+
+	if t != json.Delim('{') {
+		return fmt.Errorf("Expected JSON object")
+	}
+
+	for dec.More() {
+
+		t, err := dec.Token()
+
+		if err != nil {
+			return fmt.Errorf("Failed to read property name, %w", err)
+		}
+
+		key, ok := t.(string)
+
+		if !ok {
+			return fmt.Errorf("Invalid property name")
+		}
+
+		if key != "features" {
+
+			var v json.RawMessage
+
+			err := dec.Decode(&v)
+
+			if err != nil {
+				return fmt.Errorf("Failed to decode '%s' property, %w", key, err)
+			}
+
+			continue
+		}
+
+		t, err = dec.Token()
+
+		if err != nil {
+			return fmt.Errorf("Failed to read features token, %w", err)
+		}
+
+		if t != json.Delim('[') {
+			return fmt.Errorf("Expected features property to be a JSON array")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Missing features property")
+}
+
 // Seen() returns the total number of records processed so far.
 func (it *FeatureCollectionIterator) Seen() int64 {
 	return atomic.LoadInt64(&it.seen)
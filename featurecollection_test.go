@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -69,3 +70,56 @@ func TestFeatureCollectionIterator(t *testing.T) {
 		t.Fatalf("Failed to close iterator")
 	}
 }
+
+func TestFeatureCollectionIteratorPreservesFeatures(t *testing.T) {
+
+	ctx := context.Background()
+
+	features := []string{
+		`{"type":"Feature","properties":{"wof:name":"b","wof:id":1234567890123456789,"a":[1,2.50]},"geometry":null}`,
+		`{ "type": "Feature", "geometry": {"type":"Point","coordinates":[-122.4,37.6]}, "properties": {"z":1, "a":2} }`,
+	}
+
+	fc := `{"type":"FeatureCollection","bbox":[-122.4,37.6,-122.4,37.6],"features":[` + features[0] + `,` + features[1] + `],"other":{}}`
+
+	fc_path := filepath.Join(t.TempDir(), "collection.geojson")
+
+	err := os.WriteFile(fc_path, []byte(fc), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", fc_path, err)
+	}
+
+	it, err := NewFeatureCollectionIterator(ctx, "featurecollection://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new featurecollection source, %v", err)
+	}
+
+	i := 0
+
+	for rec, err := range it.Iterate(ctx, fc_path) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate %s, %v", fc_path, err)
+		}
+
+		body, err := io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		rec.Body.Close()
+
+		if string(body) != features[i] {
+			t.Fatalf("Unexpected body for %s: %s", rec.Path, string(body))
+		}
+
+		i += 1
+	}
+
+	if i != len(features) {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", i, len(features))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"iter"
+	"net/url"
 	"sync/atomic"
 
 	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
@@ -23,6 +24,8 @@ type FileIterator struct {
 	Iterator
 	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
 	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
 	// iterating is a boolean value indicating whether records are still being iterated.
 	iterating *atomic.Bool
 	// seen is the count of documents that have been processed.
@@ -38,18 +41,37 @@ type FileIterator struct {
 // * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
 // * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
 // * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each file. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
 func NewFileIterator(ctx context.Context, uri string) (Iterator, error) {
 
-	f, err := filters.NewQueryFiltersFromURI(ctx, uri)
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
 	}
 
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
 	it := &FileIterator{
-		filters:   f,
-		seen:      int64(0),
-		iterating: new(atomic.Bool),
+		filters:        f,
+		reader_options: reader_options,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
 	}
 
 	return it, nil
@@ -65,7 +87,7 @@ func (it *FileIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*
 
 		for _, uri := range uris {
 
-			r, err := ReaderWithPath(ctx, uri)
+			r, err := ReaderWithPathAndOptions(ctx, uri, it.reader_options)
 
 			if err != nil {
 				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err)) {
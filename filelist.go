@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"iter"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 
 	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
@@ -24,6 +26,10 @@ type FileListIterator struct {
 	Iterator
 	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
 	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
+	// follow is an optional `FollowOptions` instance used to follow (local) file lists as they are appended to.
+	follow *FollowOptions
 	// seen is the count of documents that have been processed.
 	seen int64
 	// iterating is a boolean value indicating whether records are still being iterated.
@@ -39,18 +45,46 @@ type FileListIterator struct {
 // * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
 // * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
 // * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read file lists (the files they contain are always decompressed automatically). Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+// * `?follow=` A boolean value indicating whether (local, uncompressed) file lists should be followed, like `tail -f`, yielding records for files as they are appended to the list. File lists which are truncated or rotated are reopened from the start.
+// * `?idle_timeout=` The number of seconds to wait for new files, when following file lists, before moving on to the next file list. (Default is 0, meaning file lists are followed until the context is cancelled.)
 func NewFileListIterator(ctx context.Context, uri string) (Iterator, error) {
 
-	f, err := filters.NewQueryFiltersFromURI(ctx, uri)
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
 	}
 
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	follow, err := NewFollowOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive follow options from query, %w", err)
+	}
+
 	it := &FileListIterator{
-		filters:   f,
-		seen:      int64(0),
-		iterating: new(atomic.Bool),
+		filters:        f,
+		reader_options: reader_options,
+		follow:         follow,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
 	}
 
 	return it, nil
@@ -66,17 +100,24 @@ func (it *FileListIterator) Iterate(ctx context.Context, uris ...string) iter.Se
 
 		for _, uri := range uris {
 
-			abs_path, err := filepath.Abs(uri)
+			abs_path := uri
 
-			if err != nil {
-				if !yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err)) {
-					return
+			if !isRemotePath(uri) {
+
+				v, err := filepath.Abs(uri)
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err)) {
+						return
+					}
+
+					continue
 				}
 
-				continue
+				abs_path = v
 			}
 
-			r, err := ReaderWithPath(ctx, abs_path)
+			r, err := readerWithFollowOptions(ctx, abs_path, it.reader_options, it.follow)
 
 			if err != nil {
 				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", abs_path, err)) {
@@ -94,14 +135,25 @@ func (it *FileListIterator) Iterate(ctx context.Context, uris ...string) iter.Se
 
 				select {
 				case <-ctx.Done():
-					break
+					return
 				default:
 					// pass
 				}
 
-				path := scanner.Text()
+				path := strings.TrimSpace(scanner.Text())
+
+				if path == "" {
+					continue
+				}
 
-				r2, err := ReaderWithPath(ctx, path)
+				// The compression scheme of each file in the list is always detected automatically
+
+				r2_opts := &ReaderOptions{
+					Compression: COMPRESSION_AUTO,
+					HTTP:        it.reader_options.HTTP,
+				}
+
+				r2, err := ReaderWithPathAndOptions(ctx, path, r2_opts)
 
 				if err != nil {
 					if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", path, err)) {
@@ -137,14 +189,18 @@ func (it *FileListIterator) Iterate(ctx context.Context, uris ...string) iter.Se
 				rec := NewRecord(path, r2)
 
 				if !yield(rec, nil) {
-					break
+					return
 				}
 			}
 
 			err = scanner.Err()
 
-			if err != nil {
-				yield(nil, err)
+			// Reads from file lists being followed fail when the context is cancelled
+			if err != nil && ctx.Err() == nil {
+
+				if !yield(nil, err) {
+					return
+				}
 			}
 		}
 	}
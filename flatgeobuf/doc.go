@@ -0,0 +1,26 @@
+// Package flatgeobuf provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling the features in FlatGeobuf files as GeoJSON Features. The package registers itself with the "flatgeobuf"
+// scheme so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/flatgeobuf"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "flatgeobuf://?bbox=-122.5,37.7,-122.3,37.8")
+//
+//		for rec, _ := range it.Iterate(ctx, "/usr/local/data/extract.fgb") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+//
+// FlatGeobuf files are decoded using the `google/flatbuffers` runtime according to the schema and index layout described in
+// https://github.com/flatgeobuf/flatgeobuf. Only the X and Y coordinates of geometries are decoded.
+package flatgeobuf
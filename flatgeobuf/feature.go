@@ -0,0 +1,346 @@
+package flatgeobuf
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// column is the name and type of a FlatGeobuf column.
+type column struct {
+	name string
+	typ  uint8
+}
+
+// headerColumns returns the list of columns defined in 'h'.
+func headerColumns(h *fgbHeader) []*column {
+
+	count := h.ColumnsLength()
+	columns := make([]*column, count)
+
+	for i := 0; i < count; i++ {
+		c := h.Column(i)
+		columns[i] = &column{name: c.Name(), typ: c.Type()}
+	}
+
+	return columns
+}
+
+// decodeFeature returns the GeoJSON Feature representation of 'f' using 'geom_type' as the geometry type if it is
+// not defined by the feature itself and 'columns' to decode its properties.
+func decodeFeature(f *fgbFeature, geom_type uint8, columns []*column) (gj_f *geojson.Feature, err error) {
+
+	// Malformed flatbuffers will cause the table accessors to panic (out of range errors) so trap those
+	// and return them as errors instead.
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Invalid feature, %v", r)
+		}
+	}()
+
+	geom, err := featureGeometry(f, geom_type)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.ColumnsLength() > 0 {
+
+		count := f.ColumnsLength()
+		columns = make([]*column, count)
+
+		for i := 0; i < count; i++ {
+			c := f.Column(i)
+			columns[i] = &column{name: c.Name(), typ: c.Type()}
+		}
+	}
+
+	props, err := decodeProperties(f.Properties(), columns)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode properties, %w", err)
+	}
+
+	gj_f = geojson.NewFeature(geom)
+	gj_f.Properties = props
+
+	return gj_f, nil
+}
+
+// featureGeometry returns the `orb.Geometry` for 'f' or nil if it does not have a geometry.
+func featureGeometry(f *fgbFeature, geom_type uint8) (orb.Geometry, error) {
+
+	g := f.Geometry()
+
+	if g == nil {
+		return nil, nil
+	}
+
+	if g.Type() != geometryTypeUnknown {
+		geom_type = g.Type()
+	}
+
+	return decodeGeometry(g, geom_type)
+}
+
+// decodeGeometry returns the `orb.Geometry` representation of 'g' for 'geom_type'.
+func decodeGeometry(g *fgbGeometry, geom_type uint8) (orb.Geometry, error) {
+
+	switch geom_type {
+	case geometryTypePoint:
+
+		if g.XYLength() < 2 {
+			return nil, fmt.Errorf("Invalid point")
+		}
+
+		return orb.Point{g.XY(0), g.XY(1)}, nil
+
+	case geometryTypeLineString:
+		return orb.LineString(points(g, 0, g.XYLength()/2)), nil
+
+	case geometryTypeMultiPoint:
+		return orb.MultiPoint(points(g, 0, g.XYLength()/2)), nil
+
+	case geometryTypePolygon:
+
+		poly := orb.Polygon{}
+
+		for _, pts := range parts(g) {
+			poly = append(poly, orb.Ring(pts))
+		}
+
+		return poly, nil
+
+	case geometryTypeMultiLineString:
+
+		mls := orb.MultiLineString{}
+
+		for _, pts := range parts(g) {
+			mls = append(mls, orb.LineString(pts))
+		}
+
+		return mls, nil
+
+	case geometryTypeMultiPolygon:
+
+		mp := orb.MultiPolygon{}
+
+		for i := 0; i < g.PartsLength(); i++ {
+
+			p, err := decodeGeometry(g.Part(i), geometryTypePolygon)
+
+			if err != nil {
+				return nil, err
+			}
+
+			mp = append(mp, p.(orb.Polygon))
+		}
+
+		return mp, nil
+
+	case geometryTypeGeometryCollection:
+
+		c := orb.Collection{}
+
+		for i := 0; i < g.PartsLength(); i++ {
+
+			part := g.Part(i)
+			p, err := decodeGeometry(part, part.Type())
+
+			if err != nil {
+				return nil, err
+			}
+
+			c = append(c, p)
+		}
+
+		return c, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported geometry type %d", geom_type)
+	}
+}
+
+// points returns the list of points in 'g' between the (point) indices 'start' and 'end'.
+func points(g *fgbGeometry, start int, end int) []orb.Point {
+
+	pts := make([]orb.Point, 0, end-start)
+
+	for i := start; i < end; i++ {
+		pts = append(pts, orb.Point{g.XY(i * 2), g.XY(i*2 + 1)})
+	}
+
+	return pts
+}
+
+// parts returns the lists of points in 'g' delimited by its "ends" vector. If 'g' has no ends all its points are
+// returned as a single part.
+func parts(g *fgbGeometry) [][]orb.Point {
+
+	count := g.XYLength() / 2
+
+	if g.EndsLength() == 0 {
+		return [][]orb.Point{points(g, 0, count)}
+	}
+
+	parts := make([][]orb.Point, 0, g.EndsLength())
+	start := 0
+
+	for i := 0; i < g.EndsLength(); i++ {
+
+		end := int(g.Ends(i))
+
+		if end < start || end > count {
+			panic(fmt.Sprintf("invalid end %d", end))
+		}
+
+		parts = append(parts, points(g, start, end))
+		start = end
+	}
+
+	return parts
+}
+
+// decodeProperties decodes the FlatGeobuf encoded 'buf' in to a dictionary of properties using 'columns'.
+func decodeProperties(buf []byte, columns []*column) (map[string]any, error) {
+
+	props := make(map[string]any)
+	offset := 0
+
+	read := func(size int) ([]byte, error) {
+
+		if offset+size > len(buf) {
+			return nil, fmt.Errorf("Unexpected end of properties")
+		}
+
+		b := buf[offset : offset+size]
+		offset += size
+
+		return b, nil
+	}
+
+	for offset < len(buf) {
+
+		b, err := read(2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		idx := int(binary.LittleEndian.Uint16(b))
+
+		if idx >= len(columns) {
+			return nil, fmt.Errorf("Invalid column index %d", idx)
+		}
+
+		col := columns[idx]
+
+		var v any
+
+		switch col.typ {
+		case columnTypeByte, columnTypeUByte, columnTypeBool:
+
+			b, err = read(1)
+
+			if err == nil {
+
+				switch col.typ {
+				case columnTypeByte:
+					v = int8(b[0])
+				case columnTypeUByte:
+					v = b[0]
+				default:
+					v = b[0] != 0
+				}
+			}
+
+		case columnTypeShort, columnTypeUShort:
+
+			b, err = read(2)
+
+			if err == nil {
+
+				u := binary.LittleEndian.Uint16(b)
+
+				if col.typ == columnTypeShort {
+					v = int16(u)
+				} else {
+					v = u
+				}
+			}
+
+		case columnTypeInt, columnTypeUInt, columnTypeFloat:
+
+			b, err = read(4)
+
+			if err == nil {
+
+				u := binary.LittleEndian.Uint32(b)
+
+				switch col.typ {
+				case columnTypeInt:
+					v = int32(u)
+				case columnTypeUInt:
+					v = u
+				default:
+					v = math.Float32frombits(u)
+				}
+			}
+
+		case columnTypeLong, columnTypeULong, columnTypeDouble:
+
+			b, err = read(8)
+
+			if err == nil {
+
+				u := binary.LittleEndian.Uint64(b)
+
+				switch col.typ {
+				case columnTypeLong:
+					v = int64(u)
+				case columnTypeULong:
+					v = u
+				default:
+					v = math.Float64frombits(u)
+				}
+			}
+
+		case columnTypeString, columnTypeJson, columnTypeDateTime, columnTypeBinary:
+
+			b, err = read(4)
+
+			if err == nil {
+
+				b, err = read(int(binary.LittleEndian.Uint32(b)))
+
+				if err == nil {
+
+					switch col.typ {
+					case columnTypeJson:
+						v = json.RawMessage(append([]byte(nil), b...))
+					case columnTypeBinary:
+						v = append([]byte(nil), b...)
+					default:
+						v = string(b)
+					}
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported type %d for column '%s'", col.typ, col.name)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read value for column '%s', %w", col.name, err)
+		}
+
+		props[col.name] = v
+	}
+
+	return props, nil
+}
@@ -0,0 +1,405 @@
+package flatgeobuf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync/atomic"
+
+	"github.com/paulmach/orb"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/internal/bbox"
+)
+
+// MAGIC_BYTES are the first bytes of a FlatGeobuf (version 3) file. The eighth byte, which is the patch version, is not checked.
+var MAGIC_BYTES = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62}
+
+// MAX_FEATURE_SIZE is the maximum size, in bytes, of an individual feature that will be read.
+const MAX_FEATURE_SIZE uint32 = 1 << 30
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "flatgeobuf", NewFlatGeobufIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// FlatGeobufIterator implements the `Iterator` interface for crawling the features in FlatGeobuf files as GeoJSON Features.
+type FlatGeobufIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// bbox is an optional bounding box that features must intersect in order to be crawled.
+	bbox *orb.Bound
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// fgbFile is the header information for a FlatGeobuf file.
+type fgbFile struct {
+	reader io.ReaderAt
+	// geometry_type is the geometry type for all the features in the file or `geometryTypeUnknown` if it varies.
+	geometry_type uint8
+	columns       []*column
+	// index is the spatial index for the file or nil if it does not have one.
+	index *packedRTree
+	// features_start is the byte offset of the first feature in the file.
+	features_start int64
+}
+
+// NewFlatGeobufIterator() returns a new `FlatGeobufIterator` instance configured by 'uri' in the form of:
+//
+//	flatgeobuf://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?bbox=` An optional bounding box, in the form of "minx,miny,maxx,maxy", that features must intersect in order to be crawled.
+//
+// Each feature is converted in to a GeoJSON Feature whose properties are the values of the file's (or feature's) columns.
+// The `Path` property of each record is the URI of the file followed by "#" and the (zero-based) index of the feature.
+//
+// If `?bbox=` is defined and a file has a spatial index then only the features whose bounding boxes intersect it are read.
+// Otherwise every feature is read and those whose geometries do not intersect the bounding box are skipped.
+func NewFlatGeobufIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	it := &FlatGeobufIterator{
+		filters:   f,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	if q.Has("bbox") {
+
+		b, err := bbox.Parse(q.Get("bbox"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'bbox' parameter, %w", err)
+		}
+
+		it.bbox = b
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *FlatGeobufIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateFile(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateFile yields records for each feature in the FlatGeobuf file at 'uri'. It returns false if 'yield' has
+// signaled that iteration should stop.
+func (it *FlatGeobufIterator) iterateFile(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	fh, err := os.Open(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	defer fh.Close()
+
+	fgb, err := readFile(fh)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to read '%s', %w", uri, err))
+	}
+
+	if it.bbox != nil && fgb.index != nil {
+
+		results, err := fgb.index.Search(*it.bbox)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to search index for '%s', %w", uri, err))
+		}
+
+		logger.Debug("Search index", "results", len(results))
+
+		for _, r := range results {
+
+			select {
+			case <-ctx.Done():
+				return false
+			default:
+				// pass
+			}
+
+			path := fmt.Sprintf("%s#%d", uri, r.index)
+
+			buf, err := readFeatureAt(fgb.reader, fgb.features_start+int64(r.offset))
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to read '%s', %w", path, err)) {
+					return false
+				}
+
+				continue
+			}
+
+			if !it.yieldFeature(ctx, path, fgb, buf, yield) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(fh, fgb.features_start, 1<<62))
+
+	for i := 0; ; i++ {
+
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		buf, err := readFeature(br)
+
+		if err == io.EOF {
+			return true
+		}
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to read feature %d in '%s', %w", i, uri, err))
+		}
+
+		path := fmt.Sprintf("%s#%d", uri, i)
+
+		if !it.yieldFeature(ctx, path, fgb, buf, yield) {
+			return false
+		}
+	}
+}
+
+// yieldFeature decodes the (non size-prefixed) feature in 'buf' and, if it satisfies the iterator's bounding box and
+// filters, yields it as a record. It returns false if 'yield' has signaled that iteration should stop.
+func (it *FlatGeobufIterator) yieldFeature(ctx context.Context, path string, fgb *fgbFile, buf []byte, yield func(rec *iterate.Record, err error) bool) bool {
+
+	atomic.AddInt64(&it.seen, 1)
+
+	gj_f, err := decodeFeature(newFeature(buf), fgb.geometry_type, fgb.columns)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to decode '%s', %w", path, err))
+	}
+
+	if it.bbox != nil {
+
+		if gj_f.Geometry == nil || !gj_f.Geometry.Bound().Intersects(*it.bbox) {
+			return true
+		}
+	}
+
+	body, err := gj_f.MarshalJSON()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to marshal '%s', %w", path, err))
+	}
+
+	br := bytes.NewReader(body)
+	rsc, err := ioutil.NewReadSeekCloser(br)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err))
+	}
+
+	if it.filters != nil {
+
+		ok, err := iterate.ApplyFilters(ctx, rsc, it.filters)
+
+		if err != nil {
+			rsc.Close()
+			return yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err))
+		}
+
+		if !ok {
+			rsc.Close()
+			return true
+		}
+	}
+
+	rec := iterate.NewRecord(path, rsc)
+	return yield(rec, nil)
+}
+
+// readFile reads the magic bytes, header and (optional) spatial index of the FlatGeobuf file in 'r'.
+func readFile(r io.ReaderAt) (*fgbFile, error) {
+
+	prefix := make([]byte, 12)
+
+	_, err := r.ReadAt(prefix, 0)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read magic bytes, %w", err)
+	}
+
+	if !bytes.Equal(prefix[0:7], MAGIC_BYTES) {
+		return nil, fmt.Errorf("Invalid magic bytes, not a (version 3) FlatGeobuf file")
+	}
+
+	header_size := binary.LittleEndian.Uint32(prefix[8:12])
+
+	if header_size < 4 || header_size > MAX_FEATURE_SIZE {
+		return nil, fmt.Errorf("Invalid header size %d", header_size)
+	}
+
+	header_buf := make([]byte, header_size)
+
+	_, err = r.ReadAt(header_buf, 12)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read header, %w", err)
+	}
+
+	fgb, err := readHeader(header_buf)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid header, %w", err)
+	}
+
+	fgb.reader = r
+	fgb.features_start = 12 + int64(header_size)
+
+	if fgb.index != nil {
+		fgb.index.reader = r
+		fgb.index.start = fgb.features_start
+		fgb.features_start += fgb.index.Size()
+	}
+
+	return fgb, nil
+}
+
+// readHeader decodes the header flatbuffer in 'buf'. The `reader` and `start` properties of the spatial index, if
+// present, are left for the caller to assign.
+func readHeader(buf []byte) (fgb *fgbFile, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	h := newHeader(buf)
+
+	fgb = &fgbFile{
+		geometry_type: h.GeometryType(),
+		columns:       headerColumns(h),
+	}
+
+	features_count := h.FeaturesCount()
+	node_size := h.IndexNodeSize()
+
+	if features_count > 0 && node_size > 0 {
+
+		idx, err := newPackedRTree(nil, 0, features_count, node_size)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create spatial index, %w", err)
+		}
+
+		fgb.index = idx
+	}
+
+	return fgb, nil
+}
+
+// readFeatureAt reads the size-prefixed feature at 'offset' in 'r' and returns its (non size-prefixed) flatbuffer.
+func readFeatureAt(r io.ReaderAt, offset int64) ([]byte, error) {
+	return readFeature(io.NewSectionReader(r, offset, 1<<62))
+}
+
+// readFeature reads the next size-prefixed feature in 'r' and returns its (non size-prefixed) flatbuffer. It
+// returns `io.EOF` if there are no more features to read.
+func readFeature(r io.Reader) ([]byte, error) {
+
+	prefix := make([]byte, 4)
+
+	_, err := io.ReadFull(r, prefix)
+
+	if err != nil {
+
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+
+		return nil, fmt.Errorf("Failed to read feature size, %w", err)
+	}
+
+	size := binary.LittleEndian.Uint32(prefix)
+
+	if size < 4 || size > MAX_FEATURE_SIZE {
+		return nil, fmt.Errorf("Invalid feature size %d", size)
+	}
+
+	buf := make([]byte, size)
+
+	_, err = io.ReadFull(r, buf)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read feature, %w", err)
+	}
+
+	return buf, nil
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *FlatGeobufIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *FlatGeobufIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *FlatGeobufIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,485 @@
+package flatgeobuf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/paulmach/orb"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+type testFeature struct {
+	id   int64
+	name string
+	geom orb.Geometry
+}
+
+// testFeatures returns twenty points along the line x=y followed by a polygon with a hole and a multipolygon.
+func testFeatures() []*testFeature {
+
+	features := make([]*testFeature, 0)
+
+	for i := 0; i < 20; i++ {
+
+		f := &testFeature{
+			id:   int64(100 + i),
+			name: fmt.Sprintf("point %d", i),
+			geom: orb.Point{float64(i), float64(i)},
+		}
+
+		features = append(features, f)
+	}
+
+	poly := orb.Polygon{
+		orb.Ring{{100, 100}, {110, 100}, {110, 110}, {100, 110}, {100, 100}},
+		orb.Ring{{102, 102}, {102, 104}, {104, 104}, {104, 102}, {102, 102}},
+	}
+
+	mp := orb.MultiPolygon{
+		orb.Polygon{orb.Ring{{200, 200}, {201, 200}, {201, 201}, {200, 200}}},
+		orb.Polygon{orb.Ring{{210, 210}, {211, 210}, {211, 211}, {210, 210}}},
+	}
+
+	features = append(features, &testFeature{id: 200, name: "polygon", geom: poly})
+	features = append(features, &testFeature{id: 201, name: "multipolygon", geom: mp})
+
+	return features
+}
+
+// writeTestFile writes the features returned by `testFeatures` to a FlatGeobuf file in a temporary directory. If
+// 'node_size' is greater than zero the file will include a spatial index.
+func writeTestFile(t *testing.T, node_size uint16) string {
+
+	t.Helper()
+
+	features := testFeatures()
+
+	// Features
+
+	encoded := make([][]byte, len(features))
+	offsets := make([]uint64, len(features))
+	offset := uint64(0)
+
+	for i, f := range features {
+
+		b := flatbuffers.NewBuilder(0)
+
+		geom := buildGeometry(b, f.geom)
+
+		props := new(bytes.Buffer)
+		binary.Write(props, binary.LittleEndian, uint16(0))
+		binary.Write(props, binary.LittleEndian, f.id)
+		binary.Write(props, binary.LittleEndian, uint16(1))
+		binary.Write(props, binary.LittleEndian, uint32(len(f.name)))
+		props.WriteString(f.name)
+
+		props_off := b.CreateByteVector(props.Bytes())
+
+		b.StartObject(3)
+		b.PrependUOffsetTSlot(0, geom, 0)
+		b.PrependUOffsetTSlot(1, props_off, 0)
+		b.FinishSizePrefixed(b.EndObject())
+
+		encoded[i] = b.FinishedBytes()
+		offsets[i] = offset
+		offset += uint64(len(encoded[i]))
+	}
+
+	// Header
+
+	b := flatbuffers.NewBuilder(0)
+
+	columns := make([]flatbuffers.UOffsetT, 0)
+
+	for _, c := range []*column{{name: "id", typ: columnTypeLong}, {name: "name", typ: columnTypeString}} {
+
+		name := b.CreateString(c.name)
+
+		b.StartObject(2)
+		b.PrependUOffsetTSlot(0, name, 0)
+		b.PrependUint8Slot(1, c.typ, 0)
+		columns = append(columns, b.EndObject())
+	}
+
+	b.StartVector(4, len(columns), 4)
+
+	for i := len(columns) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(columns[i])
+	}
+
+	columns_off := b.EndVector(len(columns))
+
+	b.StartObject(10)
+	b.PrependUint8Slot(2, geometryTypeUnknown, 0)
+	b.PrependUOffsetTSlot(7, columns_off, 0)
+	b.PrependUint64Slot(8, uint64(len(features)), 0)
+	b.PrependUint16Slot(9, node_size, 16)
+	b.Finish(b.EndObject())
+
+	header := b.FinishedBytes()
+
+	// Write
+
+	out := new(bytes.Buffer)
+	out.Write([]byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x00})
+	binary.Write(out, binary.LittleEndian, uint32(len(header)))
+	out.Write(header)
+
+	if node_size > 0 {
+		out.Write(buildIndex(features, offsets, uint64(node_size)))
+	}
+
+	for _, enc := range encoded {
+		out.Write(enc)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.fgb")
+
+	err := os.WriteFile(path, out.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	return path
+}
+
+// buildGeometry encodes 'geom' as a FlatGeobuf "Geometry" table.
+func buildGeometry(b *flatbuffers.Builder, geom orb.Geometry) flatbuffers.UOffsetT {
+
+	var geom_type uint8
+	var rings []orb.Ring
+	var parts []flatbuffers.UOffsetT
+
+	switch g := geom.(type) {
+	case orb.Point:
+		geom_type = geometryTypePoint
+		rings = []orb.Ring{{g}}
+	case orb.Polygon:
+		geom_type = geometryTypePolygon
+		rings = g
+	case orb.MultiPolygon:
+
+		geom_type = geometryTypeMultiPolygon
+
+		for _, p := range g {
+			parts = append(parts, buildGeometry(b, p))
+		}
+	}
+
+	var parts_off, ends_off, xy_off flatbuffers.UOffsetT
+
+	if len(parts) > 0 {
+
+		b.StartVector(4, len(parts), 4)
+
+		for i := len(parts) - 1; i >= 0; i-- {
+			b.PrependUOffsetT(parts[i])
+		}
+
+		parts_off = b.EndVector(len(parts))
+	}
+
+	if len(rings) > 0 {
+
+		xy := make([]float64, 0)
+		ends := make([]uint32, 0)
+
+		for _, r := range rings {
+
+			for _, pt := range r {
+				xy = append(xy, pt[0], pt[1])
+			}
+
+			ends = append(ends, uint32(len(xy)/2))
+		}
+
+		if len(rings) > 1 {
+
+			b.StartVector(4, len(ends), 4)
+
+			for i := len(ends) - 1; i >= 0; i-- {
+				b.PrependUint32(ends[i])
+			}
+
+			ends_off = b.EndVector(len(ends))
+		}
+
+		b.StartVector(8, len(xy), 8)
+
+		for i := len(xy) - 1; i >= 0; i-- {
+			b.PrependFloat64(xy[i])
+		}
+
+		xy_off = b.EndVector(len(xy))
+	}
+
+	b.StartObject(8)
+
+	if ends_off != 0 {
+		b.PrependUOffsetTSlot(0, ends_off, 0)
+	}
+
+	if xy_off != 0 {
+		b.PrependUOffsetTSlot(1, xy_off, 0)
+	}
+
+	b.PrependUint8Slot(6, geom_type, 0)
+
+	if parts_off != 0 {
+		b.PrependUOffsetTSlot(7, parts_off, 0)
+	}
+
+	return b.EndObject()
+}
+
+// buildIndex encodes a packed R-tree index for 'features', in the order they are written to the file, whose byte
+// offsets are 'offsets'.
+func buildIndex(features []*testFeature, offsets []uint64, node_size uint64) []byte {
+
+	num_nodes, level_bounds := levelBounds(uint64(len(features)), node_size)
+	nodes := make([]nodeItem, num_nodes)
+
+	for i, f := range features {
+		nodes[level_bounds[0][0]+uint64(i)] = nodeItem{bounds: f.geom.Bound(), offset: offsets[i]}
+	}
+
+	for level := 1; level < len(level_bounds); level++ {
+
+		children := level_bounds[level-1]
+
+		for i := level_bounds[level][0]; i < level_bounds[level][1]; i++ {
+
+			first := children[0] + (i-level_bounds[level][0])*node_size
+			last := min(first+node_size, children[1])
+
+			n := nodeItem{bounds: nodes[first].bounds, offset: first}
+
+			for j := first + 1; j < last; j++ {
+				n.bounds = n.bounds.Union(nodes[j].bounds)
+			}
+
+			nodes[i] = n
+		}
+	}
+
+	out := new(bytes.Buffer)
+
+	for _, n := range nodes {
+
+		for _, v := range []float64{n.bounds.Min[0], n.bounds.Min[1], n.bounds.Max[0], n.bounds.Max[1]} {
+			binary.Write(out, binary.LittleEndian, math.Float64bits(v))
+		}
+
+		binary.Write(out, binary.LittleEndian, n.offset)
+	}
+
+	return out.Bytes()
+}
+
+type testGeoJSON struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func TestFlatGeobufIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]int{
+		"flatgeobuf://":                                              22,
+		"flatgeobuf://?bbox=0,0,4.5,4.5":                             5,
+		"flatgeobuf://?bbox=-10,-10,-1,-1":                           0,
+		"flatgeobuf://?bbox=103,103,300,300":                         2,
+		"flatgeobuf://?bbox=0,0,4.5,4.5&exclude=properties.id=^100$": 4,
+		"flatgeobuf://?include=properties.name=polygon":              2,
+	}
+
+	for _, node_size := range []uint16{4, 16, 0} {
+
+		path := writeTestFile(t, node_size)
+
+		for iter_uri, expected := range tests {
+
+			it, err := iterate.NewIterator(ctx, iter_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new flatgeobuf source for '%s', %v", iter_uri, err)
+			}
+
+			count := 0
+
+			for rec, err := range it.Iterate(ctx, path) {
+
+				if err != nil {
+					t.Fatalf("Failed to iterate '%s' with '%s' (node size %d), %v", path, iter_uri, node_size, err)
+				}
+
+				defer rec.Body.Close()
+
+				body, err := io.ReadAll(rec.Body)
+
+				if err != nil {
+					t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+				}
+
+				var f testGeoJSON
+
+				err = json.Unmarshal(body, &f)
+
+				if err != nil {
+					t.Fatalf("Failed to unmarshal %s, %v", rec.Path, err)
+				}
+
+				if f.Type != "Feature" || f.Geometry == nil {
+					t.Fatalf("Invalid feature for %s, %s", rec.Path, string(body))
+				}
+
+				id, ok := f.Properties["id"].(float64)
+
+				if !ok {
+					t.Fatalf("Missing id property for %s, %s", rec.Path, string(body))
+				}
+
+				expected_path := fmt.Sprintf("%s#%d", path, int(id)-100)
+
+				switch id {
+				case 200:
+
+					expected_path = fmt.Sprintf("%s#20", path)
+
+					var coords [][][]float64
+
+					err := json.Unmarshal(f.Geometry.Coordinates, &coords)
+
+					if err != nil {
+						t.Fatalf("Failed to unmarshal coordinates for %s, %v", rec.Path, err)
+					}
+
+					if f.Geometry.Type != "Polygon" || len(coords) != 2 {
+						t.Fatalf("Expected polygon with interior ring for %s, %s", rec.Path, string(body))
+					}
+
+				case 201:
+
+					expected_path = fmt.Sprintf("%s#21", path)
+
+					var coords [][][][]float64
+
+					err := json.Unmarshal(f.Geometry.Coordinates, &coords)
+
+					if err != nil {
+						t.Fatalf("Failed to unmarshal coordinates for %s, %v", rec.Path, err)
+					}
+
+					if f.Geometry.Type != "MultiPolygon" || len(coords) != 2 {
+						t.Fatalf("Expected multipolygon with two polygons for %s, %s", rec.Path, string(body))
+					}
+
+				default:
+
+					if f.Geometry.Type != "Point" || f.Properties["name"] != fmt.Sprintf("point %d", int(id)-100) {
+						t.Fatalf("Unexpected feature for %s, %s", rec.Path, string(body))
+					}
+				}
+
+				if rec.Path != expected_path {
+					t.Fatalf("Unexpected path. Got %s but expected %s", rec.Path, expected_path)
+				}
+
+				count += 1
+			}
+
+			if count != expected {
+				t.Fatalf("Unexpected record count for '%s' (node size %d). Got %d but expected %d", iter_uri, node_size, count, expected)
+			}
+		}
+	}
+}
+
+func TestFlatGeobufIteratorIndex(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Use the "raw" iterator to check how many features were actually decoded
+
+	tests := map[uint16]int64{
+		4:  5,
+		16: 5,
+		0:  22,
+	}
+
+	for node_size, expected := range tests {
+
+		path := writeTestFile(t, node_size)
+
+		it, err := NewFlatGeobufIterator(ctx, "flatgeobuf://?bbox=0,0,4.5,4.5")
+
+		if err != nil {
+			t.Fatalf("Failed to create new flatgeobuf source, %v", err)
+		}
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s', %v", path, err)
+			}
+
+			rec.Body.Close()
+		}
+
+		seen := it.Seen()
+
+		if seen != expected {
+			t.Fatalf("Unexpected number of features decoded (node size %d). Got %d but expected %d", node_size, seen, expected)
+		}
+	}
+}
+
+func TestFlatGeobufIteratorInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, iter_uri := range []string{"flatgeobuf://?bbox=1,2,3", "flatgeobuf://?bbox=4,4,0,0", "flatgeobuf://?bbox=a,b,c,d"} {
+
+		_, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", iter_uri)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "invalid.fgb")
+
+	err := os.WriteFile(path, []byte(`{"type":"FeatureCollection","features":[]}`), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	it, err := NewFlatGeobufIterator(ctx, "flatgeobuf://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new flatgeobuf source, %v", err)
+	}
+
+	for _, err := range it.Iterate(ctx, path) {
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", path)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package flatgeobuf
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/paulmach/orb"
+)
+
+// NODE_ITEM_SIZE is the size, in bytes, of a node in a FlatGeobuf packed Hilbert R-tree index.
+const NODE_ITEM_SIZE int64 = 40
+
+// nodeItem is a node in a FlatGeobuf packed Hilbert R-tree index. For leaf nodes 'offset' is the byte offset of
+// a feature relative to the start of the features section. For all other nodes it is the index of the node's first child.
+type nodeItem struct {
+	bounds orb.Bound
+	offset uint64
+}
+
+// searchResult is a feature whose bounding box intersects a search query.
+type searchResult struct {
+	// offset is the byte offset of the feature relative to the start of the features section.
+	offset uint64
+	// index is the (zero-based) position of the feature in the file.
+	index uint64
+}
+
+// packedRTree provides read access to a FlatGeobuf packed Hilbert R-tree index.
+type packedRTree struct {
+	reader       io.ReaderAt
+	start        int64
+	num_items    uint64
+	node_size    uint64
+	num_nodes    uint64
+	level_bounds [][2]uint64
+}
+
+// newPackedRTree returns a new `packedRTree` instance for an index of 'num_items' features with nodes of 'node_size'
+// children stored in 'r' starting at 'start'.
+func newPackedRTree(r io.ReaderAt, start int64, num_items uint64, node_size uint16) (*packedRTree, error) {
+
+	if num_items == 0 {
+		return nil, fmt.Errorf("Index must contain at least one item")
+	}
+
+	if node_size < 2 {
+		return nil, fmt.Errorf("Invalid node size %d", node_size)
+	}
+
+	t := &packedRTree{
+		reader:    r,
+		start:     start,
+		num_items: num_items,
+		node_size: uint64(node_size),
+	}
+
+	t.num_nodes, t.level_bounds = levelBounds(num_items, uint64(node_size))
+
+	return t, nil
+}
+
+// Size returns the size, in bytes, of the index.
+func (t *packedRTree) Size() int64 {
+	return int64(t.num_nodes) * NODE_ITEM_SIZE
+}
+
+// Search returns the list of features whose bounding boxes intersect 'bbox' sorted by their position in the file.
+func (t *packedRTree) Search(bbox orb.Bound) ([]*searchResult, error) {
+
+	type entry struct {
+		index uint64
+		level int
+	}
+
+	results := make([]*searchResult, 0)
+
+	leaf_start := t.level_bounds[0][0]
+	queue := []entry{{index: 0, level: len(t.level_bounds) - 1}}
+
+	for len(queue) > 0 {
+
+		e := queue[0]
+		queue = queue[1:]
+
+		if e.level < 0 {
+			return nil, fmt.Errorf("Invalid index, node %d is below the leaf nodes", e.index)
+		}
+
+		is_leaf := e.index >= leaf_start
+		end := min(e.index+t.node_size, t.level_bounds[e.level][1])
+
+		nodes, err := t.readNodes(e.index, end)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i, n := range nodes {
+
+			if !n.bounds.Intersects(bbox) {
+				continue
+			}
+
+			pos := e.index + uint64(i)
+
+			if is_leaf {
+				results = append(results, &searchResult{offset: n.offset, index: pos - leaf_start})
+				continue
+			}
+
+			queue = append(queue, entry{index: n.offset, level: e.level - 1})
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *searchResult) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	return results, nil
+}
+
+// readNodes reads the nodes in the index between 'start' (inclusive) and 'end' (exclusive).
+func (t *packedRTree) readNodes(start uint64, end uint64) ([]*nodeItem, error) {
+
+	if end <= start || end > t.num_nodes {
+		return nil, fmt.Errorf("Invalid node range %d-%d", start, end)
+	}
+
+	buf := make([]byte, int64(end-start)*NODE_ITEM_SIZE)
+
+	_, err := t.reader.ReadAt(buf, t.start+int64(start)*NODE_ITEM_SIZE)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read index nodes, %w", err)
+	}
+
+	nodes := make([]*nodeItem, end-start)
+
+	for i := range nodes {
+
+		b := buf[int64(i)*NODE_ITEM_SIZE:]
+
+		min_x := math.Float64frombits(binary.LittleEndian.Uint64(b[0:8]))
+		min_y := math.Float64frombits(binary.LittleEndian.Uint64(b[8:16]))
+		max_x := math.Float64frombits(binary.LittleEndian.Uint64(b[16:24]))
+		max_y := math.Float64frombits(binary.LittleEndian.Uint64(b[24:32]))
+
+		nodes[i] = &nodeItem{
+			bounds: orb.Bound{Min: orb.Point{min_x, min_y}, Max: orb.Point{max_x, max_y}},
+			offset: binary.LittleEndian.Uint64(b[32:40]),
+		}
+	}
+
+	return nodes, nil
+}
+
+// levelBounds returns the total number of nodes in a packed R-tree index of 'num_items' items with nodes of 'node_size'
+// children and the (start, end) node indices of each level of the tree, starting with the leaf nodes. Nodes are
+// stored from the root of the tree down so the leaf nodes are the last nodes in the index.
+func levelBounds(num_items uint64, node_size uint64) (uint64, [][2]uint64) {
+
+	n := num_items
+	num_nodes := n
+	level_num_nodes := []uint64{n}
+
+	for {
+
+		n = (n + node_size - 1) / node_size
+		num_nodes += n
+		level_num_nodes = append(level_num_nodes, n)
+
+		if n == 1 {
+			break
+		}
+	}
+
+	bounds := make([][2]uint64, len(level_num_nodes))
+	offset := num_nodes
+
+	for i, size := range level_num_nodes {
+		offset -= size
+		bounds[i] = [2]uint64{offset, offset + size}
+	}
+
+	return num_nodes, bounds
+}
@@ -0,0 +1,230 @@
+package flatgeobuf
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// The following types provide read access to the tables defined in the FlatGeobuf "header.fbs" and "feature.fbs"
+// schemas. They are the equivalent of the code that `flatc` would generate for those schemas, limited to the fields
+// used by this package.
+
+// Geometry types defined by the FlatGeobuf "GeometryType" enum.
+const (
+	geometryTypeUnknown            uint8 = 0
+	geometryTypePoint              uint8 = 1
+	geometryTypeLineString         uint8 = 2
+	geometryTypePolygon            uint8 = 3
+	geometryTypeMultiPoint         uint8 = 4
+	geometryTypeMultiLineString    uint8 = 5
+	geometryTypeMultiPolygon       uint8 = 6
+	geometryTypeGeometryCollection uint8 = 7
+)
+
+// Column types defined by the FlatGeobuf "ColumnType" enum.
+const (
+	columnTypeByte     uint8 = 0
+	columnTypeUByte    uint8 = 1
+	columnTypeBool     uint8 = 2
+	columnTypeShort    uint8 = 3
+	columnTypeUShort   uint8 = 4
+	columnTypeInt      uint8 = 5
+	columnTypeUInt     uint8 = 6
+	columnTypeLong     uint8 = 7
+	columnTypeULong    uint8 = 8
+	columnTypeFloat    uint8 = 9
+	columnTypeDouble   uint8 = 10
+	columnTypeString   uint8 = 11
+	columnTypeJson     uint8 = 12
+	columnTypeDateTime uint8 = 13
+	columnTypeBinary   uint8 = 14
+)
+
+// fgbHeader provides access to a FlatGeobuf "Header" table.
+type fgbHeader struct {
+	t flatbuffers.Table
+}
+
+// newHeader returns a new `fgbHeader` instance for the (non size-prefixed) flatbuffer 'buf'.
+func newHeader(buf []byte) *fgbHeader {
+	h := &fgbHeader{}
+	h.t.Bytes = buf
+	h.t.Pos = flatbuffers.GetUOffsetT(buf)
+	return h
+}
+
+func (h *fgbHeader) GeometryType() uint8 {
+	return h.t.GetUint8Slot(8, 0)
+}
+
+func (h *fgbHeader) ColumnsLength() int {
+
+	o := flatbuffers.UOffsetT(h.t.Offset(18))
+
+	if o == 0 {
+		return 0
+	}
+
+	return h.t.VectorLen(o)
+}
+
+func (h *fgbHeader) Column(i int) *fgbColumn {
+
+	o := flatbuffers.UOffsetT(h.t.Offset(18))
+	x := h.t.Vector(o) + flatbuffers.UOffsetT(i)*4
+
+	c := &fgbColumn{}
+	c.t.Bytes = h.t.Bytes
+	c.t.Pos = h.t.Indirect(x)
+	return c
+}
+
+func (h *fgbHeader) FeaturesCount() uint64 {
+	return h.t.GetUint64Slot(20, 0)
+}
+
+func (h *fgbHeader) IndexNodeSize() uint16 {
+	return h.t.GetUint16Slot(22, 16)
+}
+
+// fgbColumn provides access to a FlatGeobuf "Column" table.
+type fgbColumn struct {
+	t flatbuffers.Table
+}
+
+func (c *fgbColumn) Name() string {
+
+	o := flatbuffers.UOffsetT(c.t.Offset(4))
+
+	if o == 0 {
+		return ""
+	}
+
+	return string(c.t.ByteVector(o + c.t.Pos))
+}
+
+func (c *fgbColumn) Type() uint8 {
+	return c.t.GetUint8Slot(6, 0)
+}
+
+// fgbFeature provides access to a FlatGeobuf "Feature" table.
+type fgbFeature struct {
+	t flatbuffers.Table
+}
+
+// newFeature returns a new `fgbFeature` instance for the (non size-prefixed) flatbuffer 'buf'.
+func newFeature(buf []byte) *fgbFeature {
+	f := &fgbFeature{}
+	f.t.Bytes = buf
+	f.t.Pos = flatbuffers.GetUOffsetT(buf)
+	return f
+}
+
+func (f *fgbFeature) Geometry() *fgbGeometry {
+
+	o := flatbuffers.UOffsetT(f.t.Offset(4))
+
+	if o == 0 {
+		return nil
+	}
+
+	g := &fgbGeometry{}
+	g.t.Bytes = f.t.Bytes
+	g.t.Pos = f.t.Indirect(o + f.t.Pos)
+	return g
+}
+
+func (f *fgbFeature) Properties() []byte {
+
+	o := flatbuffers.UOffsetT(f.t.Offset(6))
+
+	if o == 0 {
+		return nil
+	}
+
+	return f.t.ByteVector(o + f.t.Pos)
+}
+
+func (f *fgbFeature) ColumnsLength() int {
+
+	o := flatbuffers.UOffsetT(f.t.Offset(8))
+
+	if o == 0 {
+		return 0
+	}
+
+	return f.t.VectorLen(o)
+}
+
+func (f *fgbFeature) Column(i int) *fgbColumn {
+
+	o := flatbuffers.UOffsetT(f.t.Offset(8))
+	x := f.t.Vector(o) + flatbuffers.UOffsetT(i)*4
+
+	c := &fgbColumn{}
+	c.t.Bytes = f.t.Bytes
+	c.t.Pos = f.t.Indirect(x)
+	return c
+}
+
+// fgbGeometry provides access to a FlatGeobuf "Geometry" table.
+type fgbGeometry struct {
+	t flatbuffers.Table
+}
+
+func (g *fgbGeometry) EndsLength() int {
+
+	o := flatbuffers.UOffsetT(g.t.Offset(4))
+
+	if o == 0 {
+		return 0
+	}
+
+	return g.t.VectorLen(o)
+}
+
+func (g *fgbGeometry) Ends(i int) uint32 {
+	o := flatbuffers.UOffsetT(g.t.Offset(4))
+	return g.t.GetUint32(g.t.Vector(o) + flatbuffers.UOffsetT(i)*4)
+}
+
+func (g *fgbGeometry) XYLength() int {
+
+	o := flatbuffers.UOffsetT(g.t.Offset(6))
+
+	if o == 0 {
+		return 0
+	}
+
+	return g.t.VectorLen(o)
+}
+
+func (g *fgbGeometry) XY(i int) float64 {
+	o := flatbuffers.UOffsetT(g.t.Offset(6))
+	return g.t.GetFloat64(g.t.Vector(o) + flatbuffers.UOffsetT(i)*8)
+}
+
+func (g *fgbGeometry) Type() uint8 {
+	return g.t.GetUint8Slot(16, 0)
+}
+
+func (g *fgbGeometry) PartsLength() int {
+
+	o := flatbuffers.UOffsetT(g.t.Offset(18))
+
+	if o == 0 {
+		return 0
+	}
+
+	return g.t.VectorLen(o)
+}
+
+func (g *fgbGeometry) Part(i int) *fgbGeometry {
+
+	o := flatbuffers.UOffsetT(g.t.Offset(18))
+	x := g.t.Vector(o) + flatbuffers.UOffsetT(i)*4
+
+	p := &fgbGeometry{}
+	p.t.Bytes = g.t.Bytes
+	p.t.Pos = g.t.Indirect(x)
+	return p
+}
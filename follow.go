@@ -0,0 +1,291 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FOLLOW_POLL_INTERVAL is the default interval at which files being followed are checked for new data.
+const FOLLOW_POLL_INTERVAL time.Duration = 250 * time.Millisecond
+
+// FollowOptions defines configuration options for following (local) files as they are appended to, like `tail -f`.
+type FollowOptions struct {
+	// IdleTimeout is the amount of time to wait for new data before treating a file as complete. If zero files are followed until the context is cancelled.
+	IdleTimeout time.Duration
+	// PollInterval is the interval at which files are checked for new data, truncation or rotation.
+	PollInterval time.Duration
+}
+
+// NewFollowOptionsFromQuery returns a new `FollowOptions` instance derived from 'q' or nil if following files
+// has not been enabled. 'q' is expected to contain zero or more of the following parameters:
+// * `?follow=` A boolean value indicating whether files should be followed, like `tail -f`, after their end has been reached.
+// * `?idle_timeout=` The number of seconds to wait for new data before treating a file as complete. (Default is 0, meaning files are followed until the context is cancelled.)
+func NewFollowOptionsFromQuery(ctx context.Context, q url.Values) (*FollowOptions, error) {
+
+	if !q.Has("follow") {
+		return nil, nil
+	}
+
+	follow, err := strconv.ParseBool(q.Get("follow"))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse 'follow' parameter, %w", err)
+	}
+
+	if !follow {
+		return nil, nil
+	}
+
+	opts := &FollowOptions{
+		PollInterval: FOLLOW_POLL_INTERVAL,
+	}
+
+	if q.Has("idle_timeout") {
+
+		v, err := strconv.Atoi(q.Get("idle_timeout"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'idle_timeout' parameter, %w", err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid 'idle_timeout' parameter, must not be negative")
+		}
+
+		opts.IdleTimeout = time.Duration(v) * time.Second
+	}
+
+	return opts, nil
+}
+
+// followReader implements the `io.ReadSeekCloser` interface for reading a local file, like `tail -f`, as it is
+// appended to. When the end of the file is reached reads block until more data is written, the file is truncated
+// or rotated (in which case it is reopened from the start), the idle timeout expires or the context is cancelled.
+type followReader struct {
+	ctx    context.Context
+	path   string
+	opts   *FollowOptions
+	fh     *os.File
+	info   os.FileInfo
+	offset int64
+	// last_read is the time data was last read from the file.
+	last_read time.Time
+	mu        *sync.Mutex
+}
+
+// newFollowReader returns a new `followReader` instance for the local file at 'path' configured by 'opts'.
+func newFollowReader(ctx context.Context, path string, opts *FollowOptions) (*followReader, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s, %w", path, err)
+	}
+
+	info, err := fh.Stat()
+
+	if err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("Failed to stat %s, %w", path, err)
+	}
+
+	r := &followReader{
+		ctx:       ctx,
+		path:      path,
+		opts:      opts,
+		fh:        fh,
+		info:      info,
+		last_read: time.Now(),
+		mu:        new(sync.Mutex),
+	}
+
+	return r, nil
+}
+
+// Read reads up to len(p) bytes in to 'p'. At the end of the file it waits for more data to be written. It returns
+// `io.EOF` if the idle timeout expires and the context's error if it is cancelled.
+func (r *followReader) Read(p []byte) (int, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	poll := r.opts.PollInterval
+
+	if poll <= 0 {
+		poll = FOLLOW_POLL_INTERVAL
+	}
+
+	for {
+
+		n, err := r.fh.Read(p)
+
+		if n > 0 {
+			r.offset += int64(n)
+			r.last_read = time.Now()
+			return n, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		// At the end of the file: check whether it has been truncated or rotated before waiting for more data
+
+		reopened, err := r.check()
+
+		if err != nil {
+			return 0, err
+		}
+
+		if reopened {
+			continue
+		}
+
+		if r.opts.IdleTimeout > 0 && time.Since(r.last_read) >= r.opts.IdleTimeout {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(poll):
+			// pass
+		}
+	}
+}
+
+// check determines whether the file being followed has been truncated or rotated, reopening it from the start
+// if necessary. It returns true if the file has been reopened (or rewound).
+func (r *followReader) check() (bool, error) {
+
+	logger := slog.Default()
+	logger = logger.With("path", r.path)
+
+	info, err := os.Stat(r.path)
+
+	if err != nil {
+
+		// The file has been moved (or removed) but not yet replaced. Keep waiting.
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Failed to stat %s, %w", r.path, err)
+	}
+
+	if !os.SameFile(r.info, info) {
+
+		logger.Debug("File has been rotated, reopening")
+
+		fh, err := os.Open(r.path)
+
+		if err != nil {
+
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("Failed to reopen %s, %w", r.path, err)
+		}
+
+		r.fh.Close()
+
+		r.fh = fh
+		r.info = info
+		r.offset = 0
+
+		return true, nil
+	}
+
+	if info.Size() < r.offset {
+
+		logger.Debug("File has been truncated, rewinding", "size", info.Size(), "offset", r.offset)
+
+		_, err := r.fh.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return false, fmt.Errorf("Failed to rewind %s, %w", r.path, err)
+		}
+
+		r.offset = 0
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Seek sets the offset for the next Read to 'offset', interpreted according to 'whence', in the file currently being followed.
+func (r *followReader) Seek(offset int64, whence int) (int64, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	abs, err := r.fh.Seek(offset, whence)
+
+	if err != nil {
+		return 0, err
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// Close closes the file currently being followed.
+func (r *followReader) Close() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.fh.Close()
+}
+
+// readerWithFollowOptions returns a new `io.ReadSeekCloser` for 'abs_path'. If 'follow' is not nil and 'abs_path' is a local
+// file the reader will follow the file, as it is appended to, according to 'follow'. Otherwise the reader is created using
+// `ReaderWithPathAndOptions` and 'opts'. An error is returned if a local file being followed is compressed.
+func readerWithFollowOptions(ctx context.Context, abs_path string, opts *ReaderOptions, follow *FollowOptions) (io.ReadSeekCloser, error) {
+
+	if follow == nil {
+		return ReaderWithPathAndOptions(ctx, abs_path, opts)
+	}
+
+	// Reads from STDIN already block until more data is written (or it is closed)
+	if abs_path == STDIN {
+		return ReaderWithPathAndOptions(ctx, abs_path, opts)
+	}
+
+	if isRemotePath(abs_path) {
+		slog.Warn("Only local files can be followed, reading once", "path", abs_path)
+		return ReaderWithPathAndOptions(ctx, abs_path, opts)
+	}
+
+	compression := opts.Compression
+
+	if compression == COMPRESSION_AUTO {
+
+		fh, err := os.Open(abs_path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", abs_path, err)
+		}
+
+		compression, err = detectCompression(fh, abs_path)
+		fh.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to detect compression for %s, %w", abs_path, err)
+		}
+	}
+
+	if compression != COMPRESSION_NONE {
+		return nil, fmt.Errorf("Only uncompressed files can be followed, %s has '%s' compression", abs_path, compression)
+	}
+
+	return newFollowReader(ctx, abs_path, follow)
+}
@@ -0,0 +1,332 @@
+package iterate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// followRecords iterates 'path' using 'it' in a separate goroutine and returns a channel that the path of each record
+// is sent to and a channel that is closed when iteration completes.
+func followRecords(ctx context.Context, t *testing.T, it Iterator, path string) (chan string, chan bool) {
+
+	t.Helper()
+
+	paths_ch := make(chan string, 100)
+	done_ch := make(chan bool)
+
+	go func() {
+
+		defer close(done_ch)
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Errorf("Failed to iterate %s, %v", path, err)
+				return
+			}
+
+			_, err = io.ReadAll(rec.Body)
+			rec.Body.Close()
+
+			if err != nil {
+				t.Errorf("Failed to read body for %s, %v", rec.Path, err)
+				return
+			}
+
+			paths_ch <- rec.Path
+		}
+	}()
+
+	return paths_ch, done_ch
+}
+
+// waitForRecords waits for 'count' paths to be sent to 'paths_ch' and returns them.
+func waitForRecords(t *testing.T, paths_ch chan string, count int) []string {
+
+	t.Helper()
+
+	paths := make([]string, 0)
+
+	for len(paths) < count {
+
+		select {
+		case p := <-paths_ch:
+			paths = append(paths, p)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out waiting for records. Got %d but expected %d", len(paths), count)
+		}
+	}
+
+	return paths
+}
+
+func appendToFile(t *testing.T, path string, body []byte) {
+
+	t.Helper()
+
+	fh, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to open %s, %v", path, err)
+	}
+
+	defer fh.Close()
+
+	_, err = fh.Write(body)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+}
+
+func TestGeoJSONLIteratorFollow(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+
+	path := filepath.Join(t.TempDir(), "log.geojsonl")
+
+	appendToFile(t, path, append(bytes.Join(lines, []byte("\n")), '\n'))
+
+	it, err := NewIterator(ctx, "geojsonl://?follow=true&idle_timeout=2")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	paths_ch, done_ch := followRecords(ctx, t, it, path)
+
+	waitForRecords(t, paths_ch, 2)
+
+	// Append a record in two writes; it should only be yielded once the line is complete
+
+	appendToFile(t, path, lines[0][:10])
+	time.Sleep(500 * time.Millisecond)
+
+	select {
+	case p := <-paths_ch:
+		t.Fatalf("Unexpected record for partial line, %s", p)
+	default:
+		// pass
+	}
+
+	appendToFile(t, path, append(lines[0][10:], '\n'))
+
+	paths := waitForRecords(t, paths_ch, 1)
+
+	if paths[0] != path+"#2" {
+		t.Fatalf("Unexpected path for appended record, %s", paths[0])
+	}
+
+	// Truncate the file and write a new (shorter) record
+
+	err = os.WriteFile(path, append(lines[1], '\n'), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to truncate %s, %v", path, err)
+	}
+
+	waitForRecords(t, paths_ch, 1)
+
+	// Rotate the file
+
+	err = os.Rename(path, path+".1")
+
+	if err != nil {
+		t.Fatalf("Failed to rotate %s, %v", path, err)
+	}
+
+	appendToFile(t, path, append(bytes.Join(lines, []byte("\n")), '\n'))
+
+	waitForRecords(t, paths_ch, 2)
+
+	// Iteration should stop once the idle timeout expires
+
+	select {
+	case <-done_ch:
+		// pass
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for iteration to complete")
+	}
+
+	if it.Seen() != 6 {
+		t.Fatalf("Unexpected record count. Got %d but expected 6", it.Seen())
+	}
+}
+
+func TestGeoJSONLIteratorFollowCancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "log.geojsonl")
+
+	// The fixture does not end with a newline so the last record should never be yielded
+	appendToFile(t, path, raw)
+
+	it, err := NewGeoJSONLIterator(ctx, "geojsonl://?follow=true")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	paths_ch, done_ch := followRecords(ctx, t, it, path)
+
+	waitForRecords(t, paths_ch, 1)
+
+	cancel()
+
+	select {
+	case <-done_ch:
+		// pass
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for iteration to be cancelled")
+	}
+
+	if len(paths_ch) != 0 {
+		t.Fatalf("Unexpected records after cancellation")
+	}
+}
+
+func TestFileListIteratorFollow(t *testing.T) {
+
+	ctx := context.Background()
+
+	list, err := os.ReadFile("fixtures/data.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	files := bytes.Split(bytes.TrimSpace(list), []byte("\n"))
+
+	path := filepath.Join(t.TempDir(), "files.txt")
+
+	appendToFile(t, path, fmt.Appendf(nil, "%s\n", files[0]))
+
+	it, err := NewIterator(ctx, "filelist://?follow=true&idle_timeout=1")
+
+	if err != nil {
+		t.Fatalf("Failed to create new filelist source, %v", err)
+	}
+
+	paths_ch, done_ch := followRecords(ctx, t, it, path)
+
+	waitForRecords(t, paths_ch, 1)
+
+	appendToFile(t, path, fmt.Appendf(nil, "%s\n\n%s\n", files[1], files[2]))
+
+	paths := waitForRecords(t, paths_ch, 2)
+
+	if paths[0] != string(files[1]) || paths[1] != string(files[2]) {
+		t.Fatalf("Unexpected paths for appended files, %v", paths)
+	}
+
+	select {
+	case <-done_ch:
+		// pass
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for iteration to complete")
+	}
+}
+
+func TestFileListIteratorFollowCompressed(t *testing.T) {
+
+	ctx := context.Background()
+
+	list, err := os.ReadFile("fixtures/data.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	gz := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz)
+	gz_wr.Write(list)
+	gz_wr.Close()
+
+	path := filepath.Join(t.TempDir(), "files.txt.gz")
+	appendToFile(t, path, gz.Bytes())
+
+	for _, uri := range []string{"filelist://?follow=true&idle_timeout=1", "filelist://?follow=true&idle_timeout=1&compression=gzip"} {
+
+		it, err := NewIterator(ctx, uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new filelist source, %v", err)
+		}
+
+		failed := false
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err == nil {
+				rec.Body.Close()
+				t.Fatalf("Expected following compressed file with '%s' to fail", uri)
+			}
+
+			failed = true
+		}
+
+		if !failed {
+			t.Fatalf("Expected following compressed file with '%s' to fail", uri)
+		}
+	}
+}
+
+func TestNewFollowOptionsFromQuery(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Query strings and whether they are valid
+
+	tests := map[string]bool{
+		"":                             true,
+		"follow=false":                 true,
+		"follow=true":                  true,
+		"follow=true&idle_timeout=10":  true,
+		"follow=maybe":                 false,
+		"follow=true&idle_timeout=-1":  false,
+		"follow=true&idle_timeout=ten": false,
+	}
+
+	for str_q, expected := range tests {
+
+		q, err := url.ParseQuery(str_q)
+
+		if err != nil {
+			t.Fatalf("Failed to parse query '%s', %v", str_q, err)
+		}
+
+		opts, err := NewFollowOptionsFromQuery(ctx, q)
+
+		if (err == nil) != expected {
+			t.Fatalf("Unexpected result for '%s', %v", str_q, err)
+		}
+
+		if err == nil && (opts != nil) != (q.Get("follow") == "true") {
+			t.Fatalf("Unexpected options for '%s', %v", str_q, opts)
+		}
+	}
+}
@@ -4,29 +4,58 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
 	"sync/atomic"
 
 	"github.com/whosonfirst/go-ioutil"
 	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
 )
 
+// RECORD_SEPARATOR is the ASCII record separator (RS) character used to prefix each record in a RFC 8142 GeoJSON text sequence.
+const RECORD_SEPARATOR byte = 0x1E
+
 func init() {
 	ctx := context.Background()
-	err := RegisterIterator(ctx, "geojsonl", NewGeoJSONLIterator)
 
-	if err != nil {
-		panic(err)
+	for _, scheme := range []string{"geojsonl", "geojsonseq"} {
+
+		err := RegisterIterator(ctx, scheme, NewGeoJSONLIterator)
+
+		if err != nil {
+			panic(err)
+		}
 	}
 }
 
-// GeoJSONLIterator implements the `Iterator` interface for crawling features in a line-separated GeoJSON record.
+// GeoJSONLIterator implements the `Iterator` interface for crawling features in a line-separated GeoJSON record
+// or a RFC 8142 GeoJSON text sequence.
 type GeoJSONLIterator struct {
 	Iterator
 	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
 	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
+	// seq is a boolean value indicating whether each file is a RFC 8142 GeoJSON text sequence.
+	seq bool
+	// split is the number of byte ranges that (uncompressed, local) line-separated files are divided in to and processed concurrently.
+	split int
+	// start_line is the (zero-based) line number, or index in a GeoJSON text sequence, of the first record to process in each file.
+	start_line int
+	// start_offset is the byte offset, in each (uncompressed) file, at which to start reading records.
+	start_offset int64
+	// limit is the maximum number of records to process in each file. Zero means no limit.
+	limit int
+	// follow is an optional `FollowOptions` instance used to follow (local) files as they are appended to.
+	follow *FollowOptions
 	// seen is the count of documents that have been processed.
 	seen int64
 	// iterating is a boolean value indicating whether records are still being iterated.
@@ -36,24 +65,100 @@ type GeoJSONLIterator struct {
 // NewGeojsonLIterator() returns a new `GeojsonLIterator` instance configured by 'uri' in the form of:
 //
 //	geojsonl://?{PARAMETERS}
+//	geojsonseq://?{PARAMETERS}
 //
-// Where {PARAMETERS} may be:
+// The "geojsonl" scheme expects each file to contain one GeoJSON record per line and the "geojsonseq" scheme expects
+// each file to be a RFC 8142 GeoJSON text sequence where each record is prefixed by an ASCII record separator (0x1E)
+// character. Both schemes tolerate CRLF line endings and UTF-8 byte order marks. Where {PARAMETERS} may be:
 // * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
 // * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
 // * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
 // * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each file. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+// * `?split=` The number of byte ranges, aligned to line boundaries, that each (uncompressed, local) line-separated file is divided in to and processed concurrently. Records are not yielded in file order when this is greater than one so it can not be combined with the `?_retry=` parameter. (Default is 1.)
+// * `?start_line=` The (zero-based) line number, or index in a GeoJSON text sequence, of the first record to process in each file. When used with `?start_offset=` this is the line number (or index) of the record at that offset.
+// * `?start_offset=` The byte offset, in each (uncompressed) file, at which to start reading records. This is expected to be the `Offset` (or `Offset` + `Length`) property of a previous record.
+// * `?limit=` The maximum number of records to process in each file.
+// * `?follow=` A boolean value indicating whether (local, uncompressed) files should be followed, like `tail -f`, yielding records as they are appended. Files which are truncated or rotated are reopened from the start.
+// * `?idle_timeout=` The number of seconds to wait for new records, when following files, before moving on to the next file. (Default is 0, meaning files are followed until the context is cancelled.)
+//
+// The `Path` property of each record is the URI of the file followed by "#" and the (zero-based) line number of the
+// record or, for GeoJSON text sequences, the (zero-based) index of the record in the sequence. The `Offset` and `Length`
+// properties of each record are assigned the position and size of the record (including its delimiter) in the file.
+// Files are read serially if any of the `?start_line=`, `?start_offset=`, `?limit=` or `?follow=` parameters are present.
 func NewGeoJSONLIterator(ctx context.Context, uri string) (Iterator, error) {
 
-	f, err := filters.NewQueryFiltersFromURI(ctx, uri)
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
 	}
 
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	split, err := splitFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	int_params := map[string]int{
+		"start_line":   0,
+		"start_offset": 0,
+		"limit":        0,
+	}
+
+	for k := range int_params {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.Atoi(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '%s' parameter, %w", k, err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid '%s' parameter, must not be negative", k)
+		}
+
+		int_params[k] = v
+	}
+
+	follow, err := NewFollowOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive follow options from query, %w", err)
+	}
+
 	it := &GeoJSONLIterator{
-		filters:   f,
-		seen:      int64(0),
-		iterating: new(atomic.Bool),
+		filters:        f,
+		reader_options: reader_options,
+		seq:            u.Scheme == "geojsonseq",
+		split:          split,
+		start_line:     int_params["start_line"],
+		start_offset:   int64(int_params["start_offset"]),
+		limit:          int_params["limit"],
+		follow:         follow,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
 	}
 
 	return it, nil
@@ -69,95 +174,504 @@ func (it *GeoJSONLIterator) Iterate(ctx context.Context, uris ...string) iter.Se
 
 		for _, uri := range uris {
 
-			r, err := ReaderWithPath(ctx, uri)
+			if !it.iterateFile(ctx, uri, yield) {
+				return
+			}
+		}
+	}
 
-			if err != nil {
-				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err)) {
-					return
-				}
+}
+
+// iterateFile yields records for each feature in the file at 'uri'. It returns false if 'yield' has signaled that
+// iteration should stop.
+func (it *GeoJSONLIterator) iterateFile(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	r, err := readerWithFollowOptions(ctx, uri, it.reader_options, it.follow)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err))
+	}
+
+	defer r.Close()
+
+	// When starting from an offset the line number (or index) of the record at that offset is 'it.start_line'
+	ln := 0
+
+	if it.start_offset > 0 {
+
+		err := seekReader(r, it.start_offset)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to seek to offset %d of '%s', %w", it.start_offset, uri, err))
+		}
+
+		ln = it.start_line
+	}
+
+	if it.seq {
+		return it.iterateSequence(ctx, uri, r, ln, it.start_offset, yield)
+	}
+
+	if it.split > 1 && it.start_line == 0 && it.start_offset == 0 && it.limit == 0 && it.follow == nil {
+
+		// Only uncompressed local files can be read from arbitrary offsets
+		fh, ok := r.(*os.File)
+
+		if ok && uri != STDIN {
+			return it.iterateSplit(ctx, uri, fh, yield)
+		}
+
+		slog.Warn("Unable to split compressed or remote file, reading serially", "uri", uri)
+	}
+
+	return it.iterateLines(ctx, uri, r, ln, it.start_offset, yield)
+}
+
+// IterateReader will return an `iter.Seq2[*Record, error]` for each (non-empty) line read from 'r'. The `Path` property
+// of each record is 'uri' followed by "#" and the (zero-based) line number of the record. This is used by iterators,
+// like the `exec://` iterator, which parse line-separated GeoJSON from sources other than files.
+func (it *GeoJSONLIterator) IterateReader(ctx context.Context, uri string, r io.Reader) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+		it.iterateLines(ctx, uri, r, 0, 0, yield)
+	}
+}
+
+// iterateLines yields records for each (non-empty) line read from 'r' which is part of the file at 'uri'. 'ln' and 'offset'
+// are the (zero-based) line number and byte offset, in the file, of the first line read from 'r'. It returns false if 'yield'
+// has signaled that iteration should stop.
+func (it *GeoJSONLIterator) iterateLines(ctx context.Context, uri string, r io.Reader, ln int, offset int64, yield func(rec *Record, err error) bool) bool {
+
+	// We're using ReadBytes rather than bufio.Scanner because it's entirely
+	// possible that the raw GeoJSON (LS) will be too long for bufio.Scanner
+
+	reader := bufio.NewReader(r)
+
+	processed := 0
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		if it.limit > 0 && processed >= it.limit {
+			return true
+		}
+
+		line, read_err := reader.ReadBytes('\n')
+
+		if read_err != nil && read_err != io.EOF {
+
+			// Reads from files being followed fail when the context is cancelled
+			if ctx.Err() != nil {
+				return false
 			}
 
-			defer r.Close()
+			return yield(nil, fmt.Errorf("Failed to read line %d of '%s', %w", ln, uri, read_err))
+		}
 
-			// see this - we're using ReadLine because it's entirely possible
-			// that the raw GeoJSON (LS) will be too long for bufio.Scanner
-			// see also - https://golang.org/pkg/bufio/#Reader.ReadLine
-			// (20170822/thisisaaronland)
+		body := bytes.TrimSpace(bytes.TrimPrefix(line, utf8_bom))
 
-			reader := bufio.NewReader(r)
-			raw := bytes.NewBuffer([]byte(""))
+		if len(body) > 0 && ln >= it.start_line {
 
-			i := 0
+			path := fmt.Sprintf("%s#%d", uri, ln)
 
-			for {
+			if !it.yieldRecord(ctx, path, offset, int64(len(line)), body, yield) {
+				return false
+			}
 
-				select {
-				case <-ctx.Done():
-					break
-				default:
-					// pass
-				}
+			processed += 1
+		}
 
-				path := fmt.Sprintf("%s#%d", uri, i)
-				i += 1
+		if read_err == io.EOF {
+			return true
+		}
 
-				fragment, is_prefix, err := reader.ReadLine()
+		ln += 1
+		offset += int64(len(line))
+	}
+}
 
-				if err == io.EOF {
-					break
-				}
+// iterateSequence yields records for each text in the GeoJSON text sequence read from 'r' which is the contents of the
+// file at 'uri'. 'i' and 'offset' are the (zero-based) index and byte offset, in the file, of the first text read from 'r'.
+// It returns false if 'yield' has signaled that iteration should stop.
+func (it *GeoJSONLIterator) iterateSequence(ctx context.Context, uri string, r io.Reader, i int, offset int64, yield func(rec *Record, err error) bool) bool {
 
-				if err != nil {
-					if !yield(nil, fmt.Errorf("Failed to read line at '%s', %w", path, err)) {
-						return
-					}
-				}
+	reader := bufio.NewReader(r)
 
-				raw.Write(fragment)
+	// The first chunk, preceding the first record separator, is not a record and is
+	// expected to be empty (or only contain a byte order mark).
+	preamble := true
 
-				if is_prefix {
-					continue
-				}
+	processed := 0
 
-				br := bytes.NewReader(raw.Bytes())
-				rsc, err := ioutil.NewReadSeekCloser(br)
+	for {
 
-				if err != nil {
-					if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
-						return
-					}
-				}
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		if it.limit > 0 && processed >= it.limit {
+			return true
+		}
 
-				if it.filters != nil {
+		chunk, read_err := reader.ReadBytes(RECORD_SEPARATOR)
 
-					ok, err := ApplyFilters(ctx, rsc, it.filters)
+		if read_err != nil && read_err != io.EOF {
 
-					if err != nil {
-						rsc.Close()
-						if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
-							return
-						}
-						continue
-					}
+			// Reads from files being followed fail when the context is cancelled
+			if ctx.Err() != nil {
+				return false
+			}
 
-					if !ok {
-						rsc.Close()
-						continue
-					}
+			return yield(nil, fmt.Errorf("Failed to read '%s', %w", uri, read_err))
+		}
 
-				}
+		body := bytes.TrimPrefix(bytes.TrimSuffix(chunk, []byte{RECORD_SEPARATOR}), utf8_bom)
+		body = bytes.TrimSpace(body)
 
-				rec := NewRecord(path, rsc)
+		switch {
+		case preamble:
 
-				if !yield(rec, nil) {
-					return
+			if len(body) > 0 {
+				slog.Warn("Skipping data preceding first record separator", "uri", uri)
+			}
+
+			preamble = false
+
+		case len(body) > 0:
+
+			if i >= it.start_line {
+
+				// The offset of a text is the offset of the record separator that precedes it
+				if !it.yieldText(ctx, uri, i, offset-1, chunk, body, yield) {
+					return false
 				}
 
-				raw.Reset()
+				processed += 1
+			}
+
+			i += 1
+
+		default:
+			// Multiple consecutive record separators do not denote empty records and are ignored.
+		}
+
+		if read_err == io.EOF {
+			return true
+		}
+
+		offset += int64(len(chunk))
+	}
+}
+
+// iterateSplit divides the file 'fh', opened from 'uri', in to (up to) 'it.split' byte ranges aligned to line boundaries
+// and yields records for the lines in each range concurrently. Records are not yielded in the order they occur in the file
+// but the line number in the path of each record is the same as if the file had been read serially. It returns false if
+// 'yield' has signaled that iteration should stop.
+func (it *GeoJSONLIterator) iterateSplit(ctx context.Context, uri string, fh *os.File, yield func(rec *Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	info, err := fh.Stat()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to stat '%s', %w", uri, err))
+	}
+
+	offsets, err := splitOffsets(fh, info.Size(), it.split)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to split '%s', %w", uri, err))
+	}
+
+	ranges := len(offsets) - 1
+
+	logger.Debug("Split file", "ranges", ranges)
+
+	// Count the lines in each range (concurrently) in order to derive the line number of the first line in each range
+
+	counts := make([]int, ranges)
+	count_errors := make([]error, ranges)
+
+	wg := new(sync.WaitGroup)
+
+	for idx := 0; idx < ranges; idx++ {
+
+		wg.Add(1)
+
+		go func(idx int) {
+			defer wg.Done()
+			counts[idx], count_errors[idx] = countLines(io.NewSectionReader(fh, offsets[idx], offsets[idx+1]-offsets[idx]))
+		}(idx)
+	}
+
+	wg.Wait()
+
+	err = errors.Join(count_errors...)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to count lines in '%s', %w", uri, err))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rec_ch := make(chan *Record)
+	err_ch := make(chan error)
+
+	send := func(rec *Record, err error) bool {
+
+		if err != nil {
+
+			select {
+			case <-ctx.Done():
+				return false
+			case err_ch <- err:
+				return true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			rec.Body.Close()
+			return false
+		case rec_ch <- rec:
+			return true
+		}
+	}
+
+	ln := 0
+
+	for idx := 0; idx < ranges; idx++ {
+
+		wg.Add(1)
+
+		go func(idx int, ln int) {
+			defer wg.Done()
+			r := io.NewSectionReader(fh, offsets[idx], offsets[idx+1]-offsets[idx])
+			it.iterateLines(ctx, uri, r, ln, offsets[idx], send)
+		}(idx, ln)
+
+		ln += counts[idx]
+	}
+
+	done_ch := make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done_ch)
+	}()
+
+	for {
+		select {
+		case <-done_ch:
+			return true
+		case err := <-err_ch:
+			if !yield(nil, err) {
+				return false
+			}
+		case rec := <-rec_ch:
+			if !yield(rec, nil) {
+				return false
+			}
+		}
+	}
+}
+
+// splitFromQuery returns the value of the `?split=` parameter in 'q' defaulting to 1. Since records are not yielded in a
+// repeatable order when files are split an error is returned if the `?_retry=` parameter is also enabled in 'q'; retries
+// skip the number of records yielded by previous attempts which only works if they are always yielded in the same order.
+func splitFromQuery(q url.Values) (int, error) {
+
+	if !q.Has("split") {
+		return 1, nil
+	}
+
+	split, err := strconv.Atoi(q.Get("split"))
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse 'split' parameter, %w", err)
+	}
+
+	if split < 1 {
+		return 0, fmt.Errorf("Invalid 'split' parameter, must be greater than zero")
+	}
+
+	if split > 1 && q.Has("_retry") {
+
+		retry, err := strconv.ParseBool(q.Get("_retry"))
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to parse '_retry' parameter, %w", err)
+		}
+
+		if retry {
+			return 0, fmt.Errorf("The 'split' parameter can not be used with the '_retry' parameter")
+		}
+	}
+
+	return split, nil
+}
+
+// splitOffsets returns the offsets of (up to) 'n' byte ranges of (roughly) equal size in 'r', whose total size is
+// 'size', aligned such that each range starts at the beginning of a line. The first offset is always zero and the last
+// offset is always 'size' so range i spans offsets i to i+1.
+func splitOffsets(r io.ReaderAt, size int64, n int) ([]int64, error) {
+
+	offsets := []int64{0}
+
+	buf := make([]byte, 64*1024)
+
+	for i := 1; i < n; i++ {
+
+		start := max(size*int64(i)/int64(n), offsets[len(offsets)-1])
+
+		if start < 1 {
+			continue
+		}
+
+		// Find the first newline at or after the byte preceding the (nominal) start of the range
+		pos := start - 1
+		found := false
+
+		for pos < size {
+
+			sz, err := r.ReadAt(buf, pos)
+
+			if err != nil && err != io.EOF {
+				return nil, err
 			}
+
+			idx := bytes.IndexByte(buf[:sz], '\n')
+
+			if idx >= 0 {
+				pos += int64(idx)
+				found = true
+				break
+			}
+
+			if sz == 0 {
+				break
+			}
+
+			pos += int64(sz)
+		}
+
+		if !found {
+			break
+		}
+
+		if pos+1 > offsets[len(offsets)-1] && pos+1 < size {
+			offsets = append(offsets, pos+1)
+		}
+	}
+
+	offsets = append(offsets, size)
+	return offsets, nil
+}
+
+// countLines returns the number of newline characters in 'r'.
+func countLines(r io.Reader) (int, error) {
+
+	count := 0
+	buf := make([]byte, 64*1024)
+
+	for {
+
+		sz, err := r.Read(buf)
+		count += bytes.Count(buf[:sz], []byte("\n"))
+
+		if err == io.EOF {
+			return count, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// yieldText yields a record for 'body', the trimmed contents of 'chunk' which is the i'th text in a GeoJSON text sequence
+// read from 'uri' whose (leading) record separator is at 'offset'. As per RFC 7464 texts which are truncated or otherwise
+// malformed are skipped (with a warning) and parsing continues with the next text. It returns false if 'yield' has signaled
+// that iteration should stop.
+func (it *GeoJSONLIterator) yieldText(ctx context.Context, uri string, i int, offset int64, chunk []byte, body []byte, yield func(rec *Record, err error) bool) bool {
+
+	path := fmt.Sprintf("%s#%d", uri, i)
+
+	text := bytes.TrimSuffix(chunk, []byte{RECORD_SEPARATOR})
+
+	if !json.Valid(body) {
+
+		truncated := !bytes.HasSuffix(text, []byte("\n"))
+
+		slog.Warn("Skipping malformed text in GeoJSON text sequence", "path", path, "truncated", truncated)
+		atomic.AddInt64(&it.seen, 1)
+		return true
+	}
+
+	// The length of the text includes its leading record separator
+	return it.yieldRecord(ctx, path, offset, int64(len(text)+1), body, yield)
+}
+
+// yieldRecord yields a record for 'body' with path 'path', which occupies 'length' bytes at 'offset' in the file it was read
+// from, if it is not excluded by the iterator's filters. It returns false if 'yield' has signaled that iteration should stop.
+func (it *GeoJSONLIterator) yieldRecord(ctx context.Context, path string, offset int64, length int64, body []byte, yield func(rec *Record, err error) bool) bool {
+
+	atomic.AddInt64(&it.seen, 1)
+
+	br := bytes.NewReader(body)
+	rsc, err := ioutil.NewReadSeekCloser(br)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err))
+	}
+
+	if it.filters != nil {
+
+		ok, err := ApplyFilters(ctx, rsc, it.filters)
+
+		if err != nil {
+			rsc.Close()
+			return yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err))
+		}
+
+		if !ok {
+			rsc.Close()
+			return true
 		}
 	}
 
+	rec := NewRecord(path, rsc)
+	rec.Offset = offset
+	rec.Length = length
+
+	return yield(rec, nil)
+}
+
+// seekReader advances 'r' to 'offset'. If 'r' can not be seeked (for example `STDIN` when it is a pipe) then 'offset'
+// bytes are read and discarded.
+func seekReader(r io.ReadSeeker, offset int64) error {
+
+	_, err := r.Seek(offset, io.SeekStart)
+
+	if err == nil {
+		return nil
+	}
+
+	slog.Debug("Failed to seek, reading and discarding bytes instead", "offset", offset, "error", err)
+
+	_, err = io.CopyN(io.Discard, r, offset)
+	return err
 }
 
 // Seen() returns the total number of records processed so far.
@@ -1,10 +1,18 @@
 package iterate
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -67,3 +75,506 @@ func TestGeoJSONLSIterator(t *testing.T) {
 		t.Fatalf("Failed to close iterator")
 	}
 }
+
+func TestGeoJSONLIteratorLineEndings(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+
+	// A byte order mark, CRLF line endings and blank lines
+	body := new(bytes.Buffer)
+	body.Write(utf8_bom)
+	body.Write(bytes.Join(lines, []byte("\r\n\r\n")))
+	body.WriteString("\r\n")
+
+	path := filepath.Join(t.TempDir(), "collection.geojsonl")
+
+	err = os.WriteFile(path, body.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	it, err := NewGeoJSONLIterator(ctx, "geojsonl://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	expected_paths := []string{
+		path + "#0",
+		path + "#2",
+	}
+
+	paths := make([]string, 0)
+
+	for rec, err := range it.Iterate(ctx, path) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate %s, %v", path, err)
+		}
+
+		defer rec.Body.Close()
+
+		body, err := io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		if !json.Valid(body) {
+			t.Fatalf("Invalid body for %s", rec.Path)
+		}
+
+		paths = append(paths, rec.Path)
+	}
+
+	if !slices.Equal(paths, expected_paths) {
+		t.Fatalf("Unexpected paths. Got %v but expected %v", paths, expected_paths)
+	}
+
+	if it.Seen() != 2 {
+		t.Fatalf("Unexpected record count. Got %d but expected 2", it.Seen())
+	}
+}
+
+func TestGeoJSONSeqIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+	rs := []byte{RECORD_SEPARATOR}
+
+	body := new(bytes.Buffer)
+	body.Write(utf8_bom)
+
+	// A valid text with a CRLF line ending
+	body.Write(rs)
+	body.Write(lines[0])
+	body.WriteString("\r\n")
+
+	// Consecutive record separators
+	body.Write(rs)
+	body.Write(rs)
+
+	// A malformed text
+	body.Write(rs)
+	body.WriteString(`{"type":"Feature",}`)
+	body.WriteString("\n")
+
+	// A truncated text
+	body.Write(rs)
+	body.Write(lines[1][:len(lines[1])/2])
+
+	// A valid text with a (superfluous) leading byte order mark and no trailing line feed
+	body.Write(rs)
+	body.Write(utf8_bom)
+	body.Write(lines[1])
+
+	path := filepath.Join(t.TempDir(), "collection.geojsons")
+
+	err = os.WriteFile(path, body.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	for _, iter_uri := range []string{"geojsonseq://", "geojsonseq://?exclude=properties.wof:placetype=custom"} {
+
+		it, err := NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new geojsonseq source for '%s', %v", iter_uri, err)
+		}
+
+		paths := make([]string, 0)
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate %s with '%s', %v", path, iter_uri, err)
+			}
+
+			defer rec.Body.Close()
+
+			body, err := io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			if !bytes.HasPrefix(body, []byte("{")) || !json.Valid(body) {
+				t.Fatalf("Invalid body for %s, %s", rec.Path, string(body))
+			}
+
+			paths = append(paths, rec.Path)
+		}
+
+		expected_paths := []string{
+			path + "#0",
+			path + "#3",
+		}
+
+		if strings.Contains(iter_uri, "exclude") {
+			expected_paths = []string{}
+		}
+
+		if !slices.Equal(paths, expected_paths) {
+			t.Fatalf("Unexpected paths for '%s'. Got %v but expected %v", iter_uri, paths, expected_paths)
+		}
+	}
+}
+
+func TestGeoJSONLIteratorSplit(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+
+	// 500 records interspersed with blank lines and without a trailing newline
+
+	body := new(bytes.Buffer)
+
+	for i := 0; i < 500; i++ {
+
+		if i > 0 {
+			body.WriteString("\n")
+		}
+
+		if i%7 == 0 {
+			body.WriteString("\n")
+		}
+
+		body.Write(lines[i%len(lines)])
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "large.geojsonl")
+
+	err = os.WriteFile(path, body.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	gz := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz)
+	gz_wr.Write(body.Bytes())
+	gz_wr.Close()
+
+	gz_path := filepath.Join(dir, "large.geojsonl.gz")
+
+	err = os.WriteFile(gz_path, gz.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", gz_path, err)
+	}
+
+	// Derive the expected record bodies, keyed by line number, by reading the file serially
+
+	expected := make(map[string]string)
+
+	it, err := NewGeoJSONLIterator(ctx, "geojsonl://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, path) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate %s, %v", path, err)
+		}
+
+		defer rec.Body.Close()
+
+		body, err := io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		expected[strings.TrimPrefix(rec.Path, path)] = string(body)
+	}
+
+	if len(expected) != 500 {
+		t.Fatalf("Unexpected record count. Got %d but expected 500", len(expected))
+	}
+
+	for _, split := range []int{2, 3, 8, 64, 10000} {
+
+		for _, p := range []string{path, gz_path} {
+
+			iter_uri := fmt.Sprintf("geojsonl://?split=%d", split)
+
+			it, err := NewGeoJSONLIterator(ctx, iter_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new geojsonl source for '%s', %v", iter_uri, err)
+			}
+
+			found := make(map[string]string)
+
+			for rec, err := range it.Iterate(ctx, p) {
+
+				if err != nil {
+					t.Fatalf("Failed to iterate %s with '%s', %v", p, iter_uri, err)
+				}
+
+				defer rec.Body.Close()
+
+				body, err := io.ReadAll(rec.Body)
+
+				if err != nil {
+					t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+				}
+
+				found[strings.TrimPrefix(rec.Path, p)] = string(body)
+			}
+
+			if !maps.Equal(found, expected) {
+				t.Fatalf("Unexpected records for %s with '%s'. Got %d records but expected %d", p, iter_uri, len(found), len(expected))
+			}
+		}
+	}
+
+	_, err = NewGeoJSONLIterator(ctx, "geojsonl://?split=0")
+
+	if err == nil {
+		t.Fatalf("Expected invalid split parameter to fail")
+	}
+}
+
+func TestGeoJSONLIteratorSplitRetry(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Records are not yielded in a repeatable order when files are split so they can not be retried
+
+	tests := map[string]bool{
+		"geojsonl://?split=4":                            true,
+		"geojsonl://?split=4&_retry=false":               true,
+		"geojsonl://?split=1&_retry=true":                true,
+		"geojsonl://?_retry=true&_max_retries=2":         true,
+		"geojsonl://?split=4&_retry=true":                false,
+		"geojsonl://?split=4&_retry=true&_max_retries=2": false,
+		"auto://?split=4&_retry=true":                    false,
+	}
+
+	for uri, ok := range tests {
+
+		_, err := NewIterator(ctx, uri)
+
+		if ok && err != nil {
+			t.Fatalf("Failed to create iterator for '%s', %v", uri, err)
+		}
+
+		if !ok && err == nil {
+			t.Fatalf("Expected '%s' to fail", uri)
+		}
+	}
+}
+
+func TestSplitOffsets(t *testing.T) {
+
+	body := []byte("aaaa\nbb\n\ncccccccccccccccc\nd")
+
+	tests := map[int][]int64{
+		1:  {0, 27},
+		2:  {0, 26, 27},
+		4:  {0, 8, 26, 27},
+		27: {0, 5, 8, 9, 26, 27},
+	}
+
+	for n, expected := range tests {
+
+		offsets, err := splitOffsets(bytes.NewReader(body), int64(len(body)), n)
+
+		if err != nil {
+			t.Fatalf("Failed to split into %d ranges, %v", n, err)
+		}
+
+		if !slices.Equal(offsets, expected) {
+			t.Fatalf("Unexpected offsets for %d ranges. Got %v but expected %v", n, offsets, expected)
+		}
+	}
+}
+
+func TestGeoJSONLIteratorResume(t *testing.T) {
+
+	ctx := context.Background()
+
+	raw, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+
+	dir := t.TempDir()
+
+	// 20 records, with a blank line and CRLF line endings, as line-separated records and as a GeoJSON text sequence
+
+	lines_body := new(bytes.Buffer)
+	seq_body := new(bytes.Buffer)
+
+	for i := 0; i < 20; i++ {
+
+		if i == 5 {
+			lines_body.WriteString("\r\n")
+		}
+
+		lines_body.Write(lines[i%len(lines)])
+		lines_body.WriteString("\r\n")
+
+		seq_body.WriteByte(RECORD_SEPARATOR)
+		seq_body.Write(lines[i%len(lines)])
+		seq_body.WriteString("\n")
+	}
+
+	gz := new(bytes.Buffer)
+	gz_wr := gzip.NewWriter(gz)
+	gz_wr.Write(lines_body.Bytes())
+	gz_wr.Close()
+
+	files := map[string][]byte{
+		"records.geojsonl":    lines_body.Bytes(),
+		"records.geojsonl.gz": gz.Bytes(),
+		"records.geojsons":    seq_body.Bytes(),
+	}
+
+	type result struct {
+		path   string
+		offset int64
+		length int64
+	}
+
+	collect := func(path string, iter_uri string) []result {
+
+		it, err := NewGeoJSONLIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new iterator for '%s', %v", iter_uri, err)
+		}
+
+		results := make([]result, 0)
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate %s with '%s', %v", path, iter_uri, err)
+			}
+
+			rec.Body.Close()
+			results = append(results, result{strings.TrimPrefix(rec.Path, path), rec.Offset, rec.Length})
+		}
+
+		return results
+	}
+
+	for fname, body := range files {
+
+		path := filepath.Join(dir, fname)
+
+		err := os.WriteFile(path, body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+
+		scheme := "geojsonl"
+
+		if strings.HasSuffix(fname, ".geojsons") {
+			scheme = "geojsonseq"
+		}
+
+		all := collect(path, scheme+"://")
+
+		if len(all) != 20 {
+			t.Fatalf("Unexpected record count for %s. Got %d but expected 20", fname, len(all))
+		}
+
+		// The offset and length of each record should describe its position in the (uncompressed) file
+
+		uncompressed := files["records.geojsonl"]
+
+		if scheme == "geojsonseq" {
+			uncompressed = body
+		}
+
+		for i, r := range all {
+
+			chunk := uncompressed[r.offset : r.offset+r.length]
+
+			if scheme == "geojsonseq" && chunk[0] != RECORD_SEPARATOR {
+				t.Fatalf("Expected record separator at offset %d of %s", r.offset, fname)
+			}
+
+			chunk = bytes.TrimSpace(bytes.TrimPrefix(chunk, []byte{RECORD_SEPARATOR}))
+
+			if !bytes.Equal(chunk, lines[i%len(lines)]) {
+				t.Fatalf("Unexpected record at offset %d (%d) of %s", r.offset, r.length, fname)
+			}
+
+			if i > 0 && all[i-1].offset+all[i-1].length > r.offset {
+				t.Fatalf("Overlapping records at offset %d of %s", r.offset, fname)
+			}
+		}
+
+		// Resume from a line number, from an offset and from an offset with a known line number
+
+		tests := map[string][]result{
+			fmt.Sprintf("%s://?start_line=%s", scheme, strings.TrimPrefix(all[12].path, "#")):                                                all[12:],
+			fmt.Sprintf("%s://?start_line=%s&limit=3", scheme, strings.TrimPrefix(all[12].path, "#")):                                        all[12:15],
+			fmt.Sprintf("%s://?limit=3", scheme):                                                                                             all[:3],
+			fmt.Sprintf("%s://?start_offset=%d&start_line=%s", scheme, all[12].offset, strings.TrimPrefix(all[12].path, "#")):                all[12:],
+			fmt.Sprintf("%s://?start_offset=%d&start_line=%s", scheme, all[11].offset+all[11].length, strings.TrimPrefix(all[12].path, "#")): all[12:],
+		}
+
+		for iter_uri, expected := range tests {
+
+			results := collect(path, iter_uri)
+
+			if !slices.Equal(results, expected) {
+				t.Fatalf("Unexpected results for %s with '%s'. Got %v but expected %v", fname, iter_uri, results, expected)
+			}
+		}
+
+		// Without a line number the line numbers in each path are counted from the offset
+
+		results := collect(path, fmt.Sprintf("%s://?start_offset=%d", scheme, all[12].offset))
+
+		if len(results) != 8 || results[0].path != "#0" || results[0].offset != all[12].offset {
+			t.Fatalf("Unexpected results for %s starting at offset %d, %v", fname, all[12].offset, results)
+		}
+	}
+
+	for _, iter_uri := range []string{"geojsonl://?start_line=-1", "geojsonl://?limit=ten"} {
+
+		_, err := NewGeoJSONLIterator(ctx, iter_uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", iter_uri)
+		}
+	}
+}
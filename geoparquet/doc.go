@@ -0,0 +1,23 @@
+// Package geoparquet provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling the rows of GeoParquet files as GeoJSON Features. The package registers itself with the "parquet" scheme
+// so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/geoparquet"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "parquet://?columns=id,names&include=properties.subtype=^locality$")
+//
+//		for rec, _ := range it.Iterate(ctx, "/usr/local/data/places.parquet") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+package geoparquet
@@ -0,0 +1,146 @@
+package geoparquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/paulmach/orb/encoding/wkb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// DEFAULT_GEOMETRY_COLUMN is the name of the geometry column used when a file does not contain any GeoParquet metadata.
+const DEFAULT_GEOMETRY_COLUMN string = "geometry"
+
+// geoMetadata is the subset of the GeoParquet "geo" file metadata used to locate and decode geometries.
+type geoMetadata struct {
+	PrimaryColumn string                       `json:"primary_column"`
+	Columns       map[string]geoColumnMetadata `json:"columns"`
+}
+
+// geoColumnMetadata is the subset of the GeoParquet metadata for an individual geometry column.
+type geoColumnMetadata struct {
+	Encoding string `json:"encoding"`
+}
+
+// primaryGeometryColumn returns the name of the primary geometry column in 'f' or an empty string if 'f' has no
+// geometry column. An error is returned if the column's geometries are not encoded as WKB.
+func primaryGeometryColumn(f *parquet.File) (string, error) {
+
+	v, ok := f.Lookup("geo")
+
+	if !ok {
+
+		_, exists := f.Schema().Lookup(DEFAULT_GEOMETRY_COLUMN)
+
+		if !exists {
+			return "", nil
+		}
+
+		return DEFAULT_GEOMETRY_COLUMN, nil
+	}
+
+	var md geoMetadata
+
+	err := json.Unmarshal([]byte(v), &md)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to unmarshal GeoParquet metadata, %w", err)
+	}
+
+	col := md.PrimaryColumn
+
+	if col == "" {
+		col = DEFAULT_GEOMETRY_COLUMN
+	}
+
+	col_md, ok := md.Columns[col]
+
+	if ok && !strings.EqualFold(col_md.Encoding, "WKB") {
+		return "", fmt.Errorf("Unsupported encoding '%s' for geometry column '%s'", col_md.Encoding, col)
+	}
+
+	return col, nil
+}
+
+// projectSchema returns a new `parquet.Schema` instance containing the geometry column and the (top-level) fields
+// in 'columns'. If 'columns' is empty 'schema' is returned as-is.
+func projectSchema(schema *parquet.Schema, geometry_column string, columns []string) (*parquet.Schema, error) {
+
+	if len(columns) == 0 {
+		return schema, nil
+	}
+
+	group := parquet.Group{}
+
+	for _, f := range schema.Fields() {
+
+		name := f.Name()
+
+		if name == geometry_column || slices.Contains(columns, name) {
+			group[name] = f
+		}
+	}
+
+	for _, name := range columns {
+
+		_, ok := group[name]
+
+		if !ok {
+			return nil, fmt.Errorf("Column '%s' does not exist", name)
+		}
+	}
+
+	return parquet.NewSchema(schema.Name(), group), nil
+}
+
+// rowToFeature returns the GeoJSON Feature encoding of 'row' which is expected to conform to 'schema'.
+func rowToFeature(schema *parquet.Schema, geometry_column string, row parquet.Row) ([]byte, error) {
+
+	properties := make(map[string]any)
+
+	err := schema.Reconstruct(&properties, row)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to reconstruct row, %w", err)
+	}
+
+	f := geojson.NewFeature(nil)
+
+	if geometry_column != "" {
+
+		v, ok := properties[geometry_column]
+		delete(properties, geometry_column)
+
+		if ok && v != nil {
+
+			var raw []byte
+
+			switch v := v.(type) {
+			case string:
+				raw = []byte(v)
+			case []byte:
+				raw = v
+			default:
+				return nil, fmt.Errorf("Unexpected type %T for geometry column", v)
+			}
+
+			if len(raw) > 0 {
+
+				geom, err := wkb.Unmarshal(raw)
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to decode WKB geometry, %w", err)
+				}
+
+				f.Geometry = geom
+			}
+		}
+	}
+
+	f.Properties = properties
+
+	return f.MarshalJSON()
+}
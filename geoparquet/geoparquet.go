@@ -0,0 +1,397 @@
+package geoparquet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "parquet", NewGeoParquetIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GeoParquetIterator implements the `Iterator` interface for crawling the rows of GeoParquet files as GeoJSON Features.
+type GeoParquetIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// columns is an optional list of (non-geometry) columns to include as feature properties.
+	columns []string
+	// processes is the maximum number of row groups to process simultaneously.
+	processes int
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewGeoParquetIterator() returns a new `GeoParquetIterator` instance configured by 'uri' in the form of:
+//
+//	parquet://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?columns=` An optional comma-separated list of (non-geometry) columns to include as feature properties. (Default is all columns.)
+// * `?processes=` An optional number assigning the maximum number of row groups that will be processed simultaneously. (Default is the value of `runtime.NumCPU()`.)
+//
+// Each row is converted in to a GeoJSON Feature whose geometry is decoded from the file's primary (WKB-encoded) geometry
+// column and whose properties are the values of all the other columns. The `Path` property of each record is the URI of
+// the file followed by "#" and the (zero-based) index of the row.
+//
+// Row groups whose column statistics show that they can not match `?include=` rules for top-level properties with
+// anchored literal values (for example "properties.subtype=^locality$") are skipped without being decoded.
+func NewGeoParquetIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	processes := runtime.NumCPU()
+
+	if q.Has("processes") {
+
+		v, err := strconv.Atoi(q.Get("processes"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'processes' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid 'processes' parameter, must be greater than zero")
+		}
+
+		processes = v
+	}
+
+	columns := make([]string, 0)
+
+	if q.Has("columns") {
+
+		for _, col := range strings.Split(q.Get("columns"), ",") {
+
+			col = strings.TrimSpace(col)
+
+			if col != "" {
+				columns = append(columns, col)
+			}
+		}
+	}
+
+	it := &GeoParquetIterator{
+		filters:   f,
+		columns:   columns,
+		processes: processes,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *GeoParquetIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateFile(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// rowGroupTask is a row group to be processed along with the index of its first row in the file.
+type rowGroupTask struct {
+	index  int
+	offset int64
+	group  parquet.RowGroup
+}
+
+// iterateFile yields records for each row in the GeoParquet file at 'uri'. It returns false if 'yield' has
+// signaled that iteration should stop.
+func (it *GeoParquetIterator) iterateFile(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	fh, err := os.Open(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	defer fh.Close()
+
+	info, err := fh.Stat()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to stat '%s', %w", uri, err))
+	}
+
+	pq_f, err := parquet.OpenFile(fh, info.Size())
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s' as a Parquet file, %w", uri, err))
+	}
+
+	geometry_column, err := primaryGeometryColumn(pq_f)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to determine geometry column for '%s', %w", uri, err))
+	}
+
+	schema, err := projectSchema(pq_f.Schema(), geometry_column, it.columns)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to derive schema for '%s', %w", uri, err))
+	}
+
+	conversion, err := parquet.Convert(schema, pq_f.Schema())
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to derive conversion for '%s', %w", uri, err))
+	}
+
+	predicates := newPredicates(pq_f.Schema(), it.filters)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	task_ch := make(chan *rowGroupTask)
+	rec_ch := make(chan *iterate.Record)
+	err_ch := make(chan error)
+
+	go func() {
+
+		defer close(task_ch)
+
+		offset := int64(0)
+
+		for idx, rg := range pq_f.RowGroups() {
+
+			t := &rowGroupTask{
+				index:  idx,
+				offset: offset,
+				group:  rg,
+			}
+
+			offset += rg.NumRows()
+
+			if !predicates.mayMatch(rg) {
+				logger.Debug("Skip row group", "index", idx, "rows", rg.NumRows())
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case task_ch <- t:
+				// pass
+			}
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < it.processes; i++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for t := range task_ch {
+
+				logger.Debug("Process row group", "index", t.index, "rows", t.group.NumRows())
+
+				rg := parquet.ConvertRowGroup(t.group, conversion)
+
+				for rec, err := range it.iterateRowGroup(ctx, uri, schema, geometry_column, rg, t.offset) {
+
+					if err != nil {
+
+						select {
+						case <-ctx.Done():
+							return
+						case err_ch <- err:
+							continue
+						}
+					}
+
+					select {
+					case <-ctx.Done():
+						rec.Body.Close()
+						return
+					case rec_ch <- rec:
+						// pass
+					}
+				}
+			}
+		}()
+	}
+
+	done_ch := make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done_ch)
+	}()
+
+	for {
+		select {
+		case <-done_ch:
+			return true
+		case err := <-err_ch:
+			if !yield(nil, err) {
+				return false
+			}
+		case rec := <-rec_ch:
+			if !yield(rec, nil) {
+				return false
+			}
+		}
+	}
+}
+
+// iterateRowGroup returns an `iter.Seq2[*Record, error]` for each row in 'rg' which is expected to conform to 'schema'.
+func (it *GeoParquetIterator) iterateRowGroup(ctx context.Context, uri string, schema *parquet.Schema, geometry_column string, rg parquet.RowGroup, offset int64) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		rows := rg.Rows()
+		defer rows.Close()
+
+		buf := make([]parquet.Row, 128)
+		i := offset
+
+		for {
+
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				// pass
+			}
+
+			n, read_err := rows.ReadRows(buf)
+
+			for _, row := range buf[:n] {
+
+				path := fmt.Sprintf("%s#%d", uri, i)
+				i += 1
+
+				atomic.AddInt64(&it.seen, 1)
+
+				body, err := rowToFeature(schema, geometry_column, row)
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to convert '%s' to feature, %w", path, err)) {
+						return
+					}
+
+					continue
+				}
+
+				br := bytes.NewReader(body)
+				rsc, err := ioutil.NewReadSeekCloser(br)
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
+						return
+					}
+
+					continue
+				}
+
+				if it.filters != nil {
+
+					ok, err := iterate.ApplyFilters(ctx, rsc, it.filters)
+
+					if err != nil {
+						rsc.Close()
+
+						if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
+							return
+						}
+
+						continue
+					}
+
+					if !ok {
+						rsc.Close()
+						continue
+					}
+				}
+
+				rec := iterate.NewRecord(path, rsc)
+
+				if !yield(rec, nil) {
+					return
+				}
+			}
+
+			if read_err == io.EOF {
+				return
+			}
+
+			if read_err != nil {
+				yield(nil, fmt.Errorf("Failed to read rows from '%s', %w", uri, read_err))
+				return
+			}
+		}
+	}
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *GeoParquetIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *GeoParquetIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *GeoParquetIterator) Close() error {
+	return nil
+}
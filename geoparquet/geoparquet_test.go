@@ -0,0 +1,253 @@
+package geoparquet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+type testRow struct {
+	Id        int64   `parquet:"id"`
+	Name      string  `parquet:"name"`
+	Placetype *string `parquet:"placetype,optional"`
+	Geometry  []byte  `parquet:"geometry"`
+}
+
+// writeTestFile writes a GeoParquet file with six rows, spread across three row groups, to a temporary directory.
+func writeTestFile(t *testing.T) string {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.parquet")
+
+	fh, err := os.Create(path)
+
+	if err != nil {
+		t.Fatalf("Failed to create %s, %v", path, err)
+	}
+
+	defer fh.Close()
+
+	geo := `{"version":"1.1.0","primary_column":"geometry","columns":{"geometry":{"encoding":"WKB","geometry_types":[]}}}`
+	wr := parquet.NewGenericWriter[testRow](fh, parquet.KeyValueMetadata("geo", geo))
+
+	placetypes := []string{"locality", "locality", "region", "region", "country", ""}
+
+	// A polygon with a hole
+	poly := orb.Polygon{
+		orb.Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		orb.Ring{{2, 2}, {2, 4}, {4, 4}, {4, 2}, {2, 2}},
+	}
+
+	for i, pt := range placetypes {
+
+		var geom orb.Geometry = orb.Point{float64(i), float64(i)}
+
+		if i == 5 {
+			geom = poly
+		}
+
+		enc, err := wkb.Marshal(geom)
+
+		if err != nil {
+			t.Fatalf("Failed to encode geometry, %v", err)
+		}
+
+		row := testRow{
+			Id:       int64(100 + i),
+			Name:     fmt.Sprintf("place %d", i),
+			Geometry: enc,
+		}
+
+		if pt != "" {
+			row.Placetype = &pt
+		}
+
+		_, err = wr.Write([]testRow{row})
+
+		if err != nil {
+			t.Fatalf("Failed to write row, %v", err)
+		}
+
+		if i%2 == 1 {
+
+			err := wr.Flush()
+
+			if err != nil {
+				t.Fatalf("Failed to flush row group, %v", err)
+			}
+		}
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close writer, %v", err)
+	}
+
+	return path
+}
+
+type testFeature struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func TestGeoParquetIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	path := writeTestFile(t)
+
+	tests := map[string]int{
+		"parquet://":             6,
+		"parquet://?processes=1": 6,
+		"parquet://?columns=name&include=properties.name=place%20[0-2]": 3,
+		"parquet://?include=properties.placetype=^region$":              2,
+		"parquet://?include=properties.id=^105$":                        1,
+		"parquet://?exclude=properties.placetype=locality":              4,
+	}
+
+	for iter_uri, expected := range tests {
+
+		it, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new parquet source for '%s', %v", iter_uri, err)
+		}
+
+		count := 0
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' with '%s', %v", path, iter_uri, err)
+			}
+
+			defer rec.Body.Close()
+
+			body, err := io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			var f testFeature
+
+			err = json.Unmarshal(body, &f)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal %s, %v", rec.Path, err)
+			}
+
+			if f.Type != "Feature" || f.Geometry == nil {
+				t.Fatalf("Invalid feature for %s, %s", rec.Path, string(body))
+			}
+
+			_, ok := f.Properties["geometry"]
+
+			if ok {
+				t.Fatalf("Geometry column should not be included in properties for %s", rec.Path)
+			}
+
+			if f.Properties["id"] == float64(105) {
+
+				if f.Geometry.Type != "Polygon" {
+					t.Fatalf("Unexpected geometry type for %s, %s", rec.Path, f.Geometry.Type)
+				}
+
+				var coords [][][]float64
+
+				err := json.Unmarshal(f.Geometry.Coordinates, &coords)
+
+				if err != nil {
+					t.Fatalf("Failed to unmarshal coordinates for %s, %v", rec.Path, err)
+				}
+
+				if len(coords) != 2 {
+					t.Fatalf("Expected polygon with interior ring for %s", rec.Path)
+				}
+			}
+
+			if iter_uri == "parquet://?columns=name&include=properties.name=place%20[0-2]" && len(f.Properties) != 1 {
+				t.Fatalf("Unexpected properties for %s, %v", rec.Path, f.Properties)
+			}
+
+			count += 1
+		}
+
+		if count != expected {
+			t.Fatalf("Unexpected record count for '%s'. Got %d but expected %d", iter_uri, count, expected)
+		}
+	}
+}
+
+func TestGeoParquetIteratorPushdown(t *testing.T) {
+
+	ctx := context.Background()
+
+	path := writeTestFile(t)
+
+	// Use the "raw" iterator to check how many rows were actually decoded
+
+	it, err := NewGeoParquetIterator(ctx, "parquet://?include=properties.placetype=^region$")
+
+	if err != nil {
+		t.Fatalf("Failed to create new parquet source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, path) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate '%s', %v", path, err)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(2)
+
+	if seen != expected {
+		t.Fatalf("Unexpected number of rows decoded. Got %d but expected %d", seen, expected)
+	}
+}
+
+func TestAnchoredLiteral(t *testing.T) {
+
+	tests := map[string]string{
+		"^locality$": "locality",
+		"^1234$":     "1234",
+	}
+
+	for expr, expected := range tests {
+
+		lit, ok := anchoredLiteral(expr)
+
+		if !ok || lit != expected {
+			t.Fatalf("Unexpected literal for '%s', %s (%t)", expr, lit, ok)
+		}
+	}
+
+	for _, expr := range []string{"locality", "^locality", "^local.ty$", "(?i)^locality$", "^(a|b)$"} {
+
+		_, ok := anchoredLiteral(expr)
+
+		if ok {
+			t.Fatalf("Expected '%s' not to be an anchored literal", expr)
+		}
+	}
+}
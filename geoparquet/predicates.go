@@ -0,0 +1,195 @@
+package geoparquet
+
+import (
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/aaronland/go-json-query"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+// predicate is an equality test, derived from a query filter, which can be evaluated against the statistics of a column chunk.
+type predicate struct {
+	// column is the index of the (leaf) column being tested.
+	column int
+	// value is the value that the column must contain.
+	value parquet.Value
+	// typ is the type of the column being tested.
+	typ parquet.Type
+}
+
+// predicates is a list of `predicate` instances all of which must be satisfiable in order for a row group to contain matching rows.
+type predicates []*predicate
+
+// newPredicates derives zero or more `predicate` instances from the "include" rules in 'f'. Only rules for top-level
+// properties that map to a (non-repeated) string or integer column whose regular expressions match an anchored literal
+// value (for example "properties.subtype=^locality$") can be used. Since rules in "ANY" mode only need one match to
+// succeed predicates are only derived for them if there is a single rule.
+func newPredicates(schema *parquet.Schema, f filters.Filters) predicates {
+
+	preds := make(predicates, 0)
+
+	qf, ok := f.(*filters.QueryFilters)
+
+	if !ok || qf.Include == nil {
+		return preds
+	}
+
+	qs := qf.Include
+
+	if qs.Mode == query.QUERYSET_MODE_ANY && len(qs.Queries) != 1 {
+		return preds
+	}
+
+	for _, q := range qs.Queries {
+
+		name, ok := strings.CutPrefix(q.Path, "properties.")
+
+		if !ok || strings.ContainsAny(name, ".*?#|@\\") {
+			continue
+		}
+
+		lit, ok := anchoredLiteral(q.Match.String())
+
+		if !ok {
+			continue
+		}
+
+		leaf, ok := schema.Lookup(name)
+
+		if !ok || leaf.Node.Repeated() || leaf.MaxRepetitionLevel > 0 {
+			continue
+		}
+
+		typ := leaf.Node.Type()
+
+		var v parquet.Value
+
+		switch typ.Kind() {
+		case parquet.ByteArray:
+
+			lt := typ.LogicalType()
+
+			if lt == nil {
+				continue
+			}
+
+			_, is_string := lt.Value.(*format.StringType)
+
+			if !is_string {
+				continue
+			}
+
+			v = parquet.ByteArrayValue([]byte(lit))
+
+		case parquet.Int32:
+
+			i, err := strconv.ParseInt(lit, 10, 32)
+
+			if err != nil {
+				continue
+			}
+
+			v = parquet.Int32Value(int32(i))
+
+		case parquet.Int64:
+
+			i, err := strconv.ParseInt(lit, 10, 64)
+
+			if err != nil {
+				continue
+			}
+
+			v = parquet.Int64Value(i)
+
+		default:
+			continue
+		}
+
+		p := &predicate{
+			column: leaf.ColumnIndex,
+			value:  v,
+			typ:    typ,
+		}
+
+		preds = append(preds, p)
+	}
+
+	return preds
+}
+
+// mayMatch returns false if the column statistics for 'rg' show that it can not contain any rows that satisfy all of
+// the predicates in 'preds'. Otherwise, including when statistics are not available, it returns true.
+func (preds predicates) mayMatch(rg parquet.RowGroup) bool {
+
+	if len(preds) == 0 {
+		return true
+	}
+
+	chunks := rg.ColumnChunks()
+
+	for _, p := range preds {
+
+		if p.column >= len(chunks) {
+			continue
+		}
+
+		fc, ok := chunks[p.column].(*parquet.FileColumnChunk)
+
+		if !ok {
+			continue
+		}
+
+		min, max, ok := fc.Bounds()
+
+		if !ok {
+			continue
+		}
+
+		if p.typ.Compare(p.value, min) < 0 || p.typ.Compare(p.value, max) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// anchoredLiteral returns the literal value matched by 'expr' if it takes the form of "^{LITERAL}$".
+func anchoredLiteral(expr string) (string, bool) {
+
+	re, err := syntax.Parse(expr, syntax.Perl)
+
+	if err != nil {
+		return "", false
+	}
+
+	re = re.Simplify()
+
+	if re.Op != syntax.OpConcat || len(re.Sub) != 3 {
+		return "", false
+	}
+
+	begin := re.Sub[0]
+	lit := re.Sub[1]
+	end := re.Sub[2]
+
+	if begin.Op != syntax.OpBeginText || end.Op != syntax.OpEndText {
+		return "", false
+	}
+
+	switch lit.Op {
+	case syntax.OpLiteral:
+
+		if lit.Flags&syntax.FoldCase != 0 {
+			return "", false
+		}
+
+		return string(lit.Rune), true
+
+	default:
+		return "", false
+	}
+}
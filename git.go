@@ -0,0 +1,463 @@
+package iterate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "git", NewGitIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GitIterator implements the `Iterator` interface for crawling records stored in a local Git repository
+// at a given reference (branch, tag or commit) without requiring a working tree.
+type GitIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// ref is the Git reference (branch, tag or commit hash) to read records from.
+	ref string
+	// path is the repository-relative path of the tree to crawl.
+	path string
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewGitIterator() returns a new `GitIterator` instance configured by 'uri' in the form of:
+//
+//	git://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?ref=` The branch, tag or commit hash to read records from. (Default is HEAD.)
+// * `?path=` The repository-relative path of the tree to crawl. (Default is "data".)
+//
+// URIs passed to the `Iterate` method are expected to be the paths of local (bare or non-bare) Git repositories.
+// Repositories are read using the `git` binary which is expected to be present in the current $PATH.
+func NewGitIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	ref := "HEAD"
+	path := "data"
+
+	if q.Has("ref") {
+		ref = q.Get("ref")
+	}
+
+	if q.Has("path") {
+		path = strings.Trim(q.Get("path"), "/")
+	}
+
+	it := &GitIterator{
+		filters:   f,
+		ref:       ref,
+		path:      path,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *GitIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateRepo(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateRepo yields records for each blob in the Git repository at 'uri'. It returns false if 'yield' has
+// signaled that iteration should stop.
+func (it *GitIterator) iterateRepo(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	repo, err := newGitRepo(uri)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	commit, err := repo.resolveCommit(ctx, it.ref)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	logger.Debug("Resolved reference", "ref", it.ref, "commit", commit)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blobs, err := repo.newBlobReader(ctx)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	defer blobs.Close()
+
+	args := []string{
+		"ls-tree", "-r", "-z", "--full-tree", commit,
+	}
+
+	if it.path != "" {
+		args = append(args, "--", it.path)
+	}
+
+	for entry, err := range repo.scan(ctx, args...) {
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to list tree for '%s', %w", uri, err))
+		}
+
+		// {MODE} SP {TYPE} SP {OBJECT} TAB {PATH}
+
+		info, path, ok := strings.Cut(entry, "\t")
+
+		if !ok {
+			return yield(nil, fmt.Errorf("Invalid tree entry in '%s', '%s'", uri, entry))
+		}
+
+		fields := strings.Fields(info)
+
+		if len(fields) != 3 {
+			return yield(nil, fmt.Errorf("Invalid tree entry in '%s', '%s'", uri, entry))
+		}
+
+		if fields[1] != "blob" {
+			continue
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		rec, err := newGitRecord(ctx, blobs, it.filters, path, fields[2])
+
+		if err != nil {
+
+			if !yield(nil, err) {
+				return false
+			}
+
+			continue
+		}
+
+		if rec == nil {
+			continue
+		}
+
+		if !yield(rec, nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *GitIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *GitIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *GitIterator) Close() error {
+	return nil
+}
+
+// newGitRecord returns a new `Record` instance for the blob 'object' stored at 'path' or nil if the record has
+// been excluded by 'f'.
+func newGitRecord(ctx context.Context, blobs *gitBlobReader, f filters.Filters, path string, object string) (*Record, error) {
+
+	body, err := blobs.Read(object)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read blob for '%s', %w", path, err)
+	}
+
+	br := bytes.NewReader(body)
+	rsc, err := ioutil.NewReadSeekCloser(br)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)
+	}
+
+	if f != nil {
+
+		ok, err := ApplyFilters(ctx, rsc, f)
+
+		if err != nil {
+			rsc.Close()
+			return nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)
+		}
+
+		if !ok {
+			rsc.Close()
+			return nil, nil
+		}
+	}
+
+	return NewRecord(path, rsc), nil
+}
+
+// gitRepo provides methods for reading data from a local Git repository using the `git` binary.
+type gitRepo struct {
+	path string
+}
+
+// newGitRepo returns a new `gitRepo` instance for the (bare or non-bare) repository at 'path'.
+func newGitRepo(path string) (*gitRepo, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", path, err)
+	}
+
+	r := &gitRepo{
+		path: abs_path,
+	}
+
+	return r, nil
+}
+
+// command returns a new `exec.Cmd` instance for running the git subcommand defined by 'args' in the repository.
+func (r *gitRepo) command(ctx context.Context, args ...string) *exec.Cmd {
+
+	git_args := append([]string{"-C", r.path}, args...)
+	return exec.CommandContext(ctx, "git", git_args...)
+}
+
+// resolveCommit returns the commit hash for 'ref'.
+func (r *gitRepo) resolveCommit(ctx context.Context, ref string) (string, error) {
+
+	stderr := new(bytes.Buffer)
+
+	cmd := r.command(ctx, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve '%s' in '%s', %w (%s)", ref, r.path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// scan runs the git subcommand defined by 'args', which is expected to produce NUL-terminated output, and
+// returns an iterator for each NUL-terminated value.
+func (r *gitRepo) scan(ctx context.Context, args ...string) iter.Seq2[string, error] {
+
+	return func(yield func(string, error) bool) {
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stderr := new(bytes.Buffer)
+
+		cmd := r.command(ctx, args...)
+		cmd.Stderr = stderr
+
+		stdout, err := cmd.StdoutPipe()
+
+		if err != nil {
+			yield("", fmt.Errorf("Failed to create stdout pipe, %w", err))
+			return
+		}
+
+		err = cmd.Start()
+
+		if err != nil {
+			yield("", fmt.Errorf("Failed to start git %s, %w", args[0], err))
+			return
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		scanner.Split(scanNUL)
+
+		for scanner.Scan() {
+
+			if !yield(scanner.Text(), nil) {
+				cancel()
+				cmd.Wait()
+				return
+			}
+		}
+
+		err = scanner.Err()
+
+		if err != nil {
+			cancel()
+			cmd.Wait()
+			yield("", fmt.Errorf("Failed to read git %s output, %w", args[0], err))
+			return
+		}
+
+		err = cmd.Wait()
+
+		if err != nil {
+			yield("", fmt.Errorf("Failed to run git %s, %w (%s)", args[0], err, strings.TrimSpace(stderr.String())))
+			return
+		}
+	}
+}
+
+// newBlobReader returns a new `gitBlobReader` instance for reading blobs from the repository.
+func (r *gitRepo) newBlobReader(ctx context.Context) (*gitBlobReader, error) {
+
+	cmd := r.command(ctx, "cat-file", "--batch")
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create stdin pipe, %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create stdout pipe, %w", err)
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start git cat-file, %w", err)
+	}
+
+	br := &gitBlobReader{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	return br, nil
+}
+
+// gitBlobReader reads the contents of blobs from a long-running `git cat-file --batch` process.
+type gitBlobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// Read returns the contents of the blob identified by 'object'.
+func (br *gitBlobReader) Read(object string) ([]byte, error) {
+
+	_, err := fmt.Fprintf(br.stdin, "%s\n", object)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request object, %w", err)
+	}
+
+	// {OBJECT} SP {TYPE} SP {SIZE} LF {CONTENTS} LF
+
+	header, err := br.stdout.ReadString('\n')
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read object header, %w", err)
+	}
+
+	fields := strings.Fields(header)
+
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("Unexpected object header '%s'", strings.TrimSpace(header))
+	}
+
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse object size, %w", err)
+	}
+
+	body := make([]byte, size+1)
+
+	_, err = io.ReadFull(br.stdout, body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read object, %w", err)
+	}
+
+	return body[0:size], nil
+}
+
+// Close terminates the underlying `git cat-file` process.
+func (br *gitBlobReader) Close() error {
+
+	br.stdin.Close()
+	return br.cmd.Wait()
+}
+
+// scanNUL is a `bufio.SplitFunc` for NUL-terminated values.
+func scanNUL(data []byte, at_eof bool) (int, []byte, error) {
+
+	if at_eof && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	i := bytes.IndexByte(data, 0)
+
+	if i >= 0 {
+		return i + 1, data[0:i], nil
+	}
+
+	if at_eof {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
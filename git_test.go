@@ -0,0 +1,156 @@
+package iterate
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo := newGitTestRepo(t)
+
+	// Tag the original fixtures, add a new record and then clone everything in to a bare repository
+
+	runGitTestCommand(t, repo, "tag", "v1")
+
+	err := os.WriteFile(filepath.Join(repo, "data", "101.geojson"), []byte(`{"type":"Feature","properties":{"wof:id":101},"geometry":null}`), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write new record, %v", err)
+	}
+
+	runGitTestCommand(t, repo, "add", ".")
+	runGitTestCommand(t, repo, "commit", "-q", "-m", "add 101")
+
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	runGitTestCommand(t, repo, "clone", "-q", "--bare", repo, bare)
+
+	tests := map[string]int64{
+		"git://":                  38,
+		"git://?ref=v1":           37,
+		"git://?ref=v1&path=data": 37,
+		"git://?_exclude_alt=true&include=properties.wof:id=101": 1,
+	}
+
+	for iterator_uri, expected := range tests {
+
+		for _, path := range []string{repo, bare} {
+
+			it, err := NewIterator(ctx, iterator_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new git source for %s, %v", iterator_uri, err)
+			}
+
+			for rec, err := range it.Iterate(ctx, path) {
+
+				if err != nil {
+					t.Fatalf("Failed to iterate %s with %s, %v", path, iterator_uri, err)
+				}
+
+				defer rec.Body.Close()
+
+				_, err = io.ReadAll(rec.Body)
+
+				if err != nil {
+					t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+				}
+
+				if filepath.Dir(rec.Path) == "." {
+					t.Fatalf("Expected repo-relative path, got %s", rec.Path)
+				}
+			}
+
+			seen := it.Seen()
+
+			if seen != expected {
+				t.Fatalf("Unexpected record count for %s (%s). Got %d but expected %d", iterator_uri, path, seen, expected)
+			}
+		}
+	}
+
+	it, err := NewIterator(ctx, "git://?ref=missing")
+
+	if err != nil {
+		t.Fatalf("Failed to create new git source, %v", err)
+	}
+
+	for _, err := range it.Iterate(ctx, repo) {
+
+		if err == nil {
+			t.Fatalf("Expected missing ref to fail")
+		}
+	}
+}
+
+// newGitTestRepo creates a new Git repository containing the "fixtures/data" directory
+// in a temporary directory and returns its path.
+func newGitTestRepo(t *testing.T) string {
+
+	_, err := exec.LookPath("git")
+
+	if err != nil {
+		t.Skip("git binary not found")
+	}
+
+	repo := t.TempDir()
+
+	err = os.CopyFS(repo, os.DirFS("fixtures"))
+
+	if err != nil {
+		t.Fatalf("Failed to copy fixtures, %v", err)
+	}
+
+	// Only keep the data directory
+
+	entries, err := fs.ReadDir(os.DirFS(repo), ".")
+
+	if err != nil {
+		t.Fatalf("Failed to read repo, %v", err)
+	}
+
+	for _, e := range entries {
+
+		if e.Name() == "data" {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(repo, e.Name()))
+
+		if err != nil {
+			t.Fatalf("Failed to remove %s, %v", e.Name(), err)
+		}
+	}
+
+	runGitTestCommand(t, repo, "init", "-q")
+	runGitTestCommand(t, repo, "add", ".")
+	runGitTestCommand(t, repo, "commit", "-q", "-m", "initial commit")
+
+	return repo
+}
+
+// runGitTestCommand runs the git subcommand defined by 'args' in 'repo'.
+func runGitTestCommand(t *testing.T, repo string, args ...string) {
+
+	git_args := []string{
+		"-C", repo,
+		"-c", "user.name=test",
+		"-c", "user.email=test@example.com",
+		"-c", "commit.gpgsign=false",
+	}
+
+	git_args = append(git_args, args...)
+
+	out, err := exec.Command("git", git_args...).CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("Failed to run git %v, %v (%s)", args, err, string(out))
+	}
+}
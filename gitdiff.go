@@ -0,0 +1,285 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "gitdiff", NewGitDiffIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GitDiffIterator implements the `Iterator` interface for crawling GeoJSON records that have been added, modified
+// or deleted between two commits in a local Git repository.
+type GitDiffIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// from is the Git reference (branch, tag or commit hash) to compare changes from.
+	from string
+	// to is the Git reference (branch, tag or commit hash) to compare changes to.
+	to string
+	// path is the repository-relative path of the tree to compare.
+	path string
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewGitDiffIterator() returns a new `GitDiffIterator` instance configured by 'uri' in the form of:
+//
+//	gitdiff://?from={REF}&{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?from=` The branch, tag or commit hash to compare changes from. (Required.)
+// * `?to=` The branch, tag or commit hash to compare changes to. (Default is HEAD.)
+// * `?path=` The repository-relative path of the tree to compare. (Default is "data".)
+//
+// URIs passed to the `Iterate` method are expected to be the paths of local (bare or non-bare) Git repositories.
+// Records for files that have been deleted have their `Deleted` property set to true and their body contains the
+// file as it existed in the "from" commit. Records for deleted files are always yielded; the `?include=` and `?exclude=`
+// filters are only applied to added and modified files. Renamed files are reported as a deletion of the old path and an
+// addition of the new path.
+func NewGitDiffIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	from := q.Get("from")
+
+	if from == "" {
+		return nil, fmt.Errorf("Missing ?from= parameter")
+	}
+
+	to := "HEAD"
+	path := "data"
+
+	if q.Has("to") {
+		to = q.Get("to")
+	}
+
+	if q.Has("path") {
+		path = strings.Trim(q.Get("path"), "/")
+	}
+
+	it := &GitDiffIterator{
+		filters:   f,
+		from:      from,
+		to:        to,
+		path:      path,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *GitDiffIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateRepo(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateRepo yields records for each GeoJSON file that has changed in the Git repository at 'uri'. It returns
+// false if 'yield' has signaled that iteration should stop.
+func (it *GitDiffIterator) iterateRepo(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	repo, err := newGitRepo(uri)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	from, err := repo.resolveCommit(ctx, it.from)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	to, err := repo.resolveCommit(ctx, it.to)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	logger.Debug("Resolved references", "from", from, "to", to)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blobs, err := repo.newBlobReader(ctx)
+
+	if err != nil {
+		return yield(nil, err)
+	}
+
+	defer blobs.Close()
+
+	// Rename detection is disabled so that renamed files are reported as a deletion followed by an addition
+
+	args := []string{
+		"diff-tree", "-r", "-z", "--raw", "--no-renames", "--no-commit-id", from, to,
+	}
+
+	if it.path != "" {
+		args = append(args, "--", it.path)
+	}
+
+	// With -z each change is reported as two NUL-terminated values:
+	// :{OLD MODE} SP {NEW MODE} SP {OLD OBJECT} SP {NEW OBJECT} SP {STATUS} NUL {PATH} NUL
+
+	var fields []string
+
+	for value, err := range repo.scan(ctx, args...) {
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to diff '%s', %w", uri, err))
+		}
+
+		if fields == nil {
+
+			fields = strings.Fields(strings.TrimPrefix(value, ":"))
+
+			if len(fields) != 5 {
+				return yield(nil, fmt.Errorf("Invalid diff entry in '%s', '%s'", uri, value))
+			}
+
+			continue
+		}
+
+		path := value
+		old_mode := fields[0]
+		new_mode := fields[1]
+		old_object := fields[2]
+		new_object := fields[3]
+		status := fields[4]
+
+		fields = nil
+
+		if strings.ToLower(filepath.Ext(path)) != ".geojson" {
+			continue
+		}
+
+		var object string
+		deleted := false
+
+		switch status {
+		case "A", "M", "T":
+
+			if !isGitFileMode(new_mode) {
+				continue
+			}
+
+			object = new_object
+
+		case "D":
+
+			if !isGitFileMode(old_mode) {
+				continue
+			}
+
+			object = old_object
+			deleted = true
+
+		default:
+			logger.Debug("Skip unsupported change", "path", path, "status", status)
+			continue
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		// Deletions are not filtered, since their (old) body may no longer match, so that consumers never miss them
+
+		f := it.filters
+
+		if deleted {
+			f = nil
+		}
+
+		rec, err := newGitRecord(ctx, blobs, f, path, object)
+
+		if err != nil {
+
+			if !yield(nil, err) {
+				return false
+			}
+
+			continue
+		}
+
+		if rec == nil {
+			continue
+		}
+
+		rec.Deleted = deleted
+
+		if !yield(rec, nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *GitDiffIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *GitDiffIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *GitDiffIterator) Close() error {
+	return nil
+}
+
+// isGitFileMode returns a boolean value indicating whether 'mode' is a Git (regular or executable) file mode.
+func isGitFileMode(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
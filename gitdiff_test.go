@@ -0,0 +1,136 @@
+package iterate
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitDiffIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo := newGitTestRepo(t)
+	runGitTestCommand(t, repo, "tag", "v1")
+
+	modified := "data/136/039/131/1/1360391311.geojson"
+	deleted := "data/136/039/131/3/1360391313.geojson"
+	renamed_from := "data/136/039/131/5/1360391315.geojson"
+	renamed_to := "data/136/039/131/5/renamed.geojson"
+	added := "data/101.geojson"
+
+	err := os.WriteFile(filepath.Join(repo, modified), []byte(`{"type":"Feature","properties":{"wof:id":1360391311},"geometry":null}`), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to modify record, %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(repo, added), []byte(`{"type":"Feature","properties":{"wof:id":101},"geometry":null}`), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to add record, %v", err)
+	}
+
+	runGitTestCommand(t, repo, "rm", "-q", deleted)
+	runGitTestCommand(t, repo, "mv", renamed_from, renamed_to)
+	runGitTestCommand(t, repo, "add", ".")
+	runGitTestCommand(t, repo, "commit", "-q", "-m", "changes")
+
+	expected := map[string]bool{
+		modified:     false,
+		deleted:      true,
+		renamed_from: true,
+		renamed_to:   false,
+		added:        false,
+	}
+
+	it, err := NewIterator(ctx, "gitdiff://?from=v1")
+
+	if err != nil {
+		t.Fatalf("Failed to create new gitdiff source, %v", err)
+	}
+
+	count := 0
+
+	for rec, err := range it.Iterate(ctx, repo) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate %s, %v", repo, err)
+		}
+
+		defer rec.Body.Close()
+
+		body, err := io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		if len(body) == 0 {
+			t.Fatalf("Empty body for %s", rec.Path)
+		}
+
+		is_deleted, ok := expected[rec.Path]
+
+		if !ok {
+			t.Fatalf("Unexpected record %s", rec.Path)
+		}
+
+		if rec.Deleted != is_deleted {
+			t.Fatalf("Unexpected deleted flag for %s", rec.Path)
+		}
+
+		count += 1
+	}
+
+	if count != len(expected) {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", count, len(expected))
+	}
+
+	// Filters are not applied to deleted records
+
+	it, err = NewIterator(ctx, "gitdiff://?from=v1&include=properties.wof:id=^101$")
+
+	if err != nil {
+		t.Fatalf("Failed to create new gitdiff source, %v", err)
+	}
+
+	paths := make(map[string]bool)
+
+	for rec, err := range it.Iterate(ctx, repo) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate %s, %v", repo, err)
+		}
+
+		rec.Body.Close()
+		paths[rec.Path] = rec.Deleted
+	}
+
+	expected_filtered := map[string]bool{
+		deleted:      true,
+		renamed_from: true,
+		added:        false,
+	}
+
+	if len(paths) != len(expected_filtered) {
+		t.Fatalf("Unexpected filtered records, %v", paths)
+	}
+
+	for path, is_deleted := range expected_filtered {
+
+		v, ok := paths[path]
+
+		if !ok || v != is_deleted {
+			t.Fatalf("Unexpected filtered record for %s, %v", path, paths)
+		}
+	}
+
+	_, err = NewIterator(ctx, "gitdiff://")
+
+	if err == nil {
+		t.Fatalf("Expected missing from parameter to fail")
+	}
+}
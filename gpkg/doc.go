@@ -0,0 +1,27 @@
+// Package gpkg provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling the rows of OGC GeoPackage feature tables as GeoJSON Features. The package registers itself with the "gpkg"
+// scheme so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/gpkg"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "gpkg://?table=localities&bbox=-122.5,37.7,-122.3,37.8")
+//
+//		for rec, _ := range it.Iterate(ctx, "/usr/local/data/places.gpkg") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+//
+// GeoPackages are opened read-only using the `ncruces/go-sqlite3` database/sql driver which embeds SQLite (including
+// the R*Tree module) so no external services or libraries are required. Only the X and Y coordinates of geometries
+// are decoded and they are not reprojected.
+package gpkg
@@ -0,0 +1,370 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// WKB geometry types. ISO WKB encodes Z, M and ZM variants by adding 1000, 2000 and 3000 respectively to these values.
+const (
+	wkbPoint              uint32 = 1
+	wkbLineString         uint32 = 2
+	wkbPolygon            uint32 = 3
+	wkbMultiPoint         uint32 = 4
+	wkbMultiLineString    uint32 = 5
+	wkbMultiPolygon       uint32 = 6
+	wkbGeometryCollection uint32 = 7
+)
+
+// Flags used by (PostGIS) extended WKB to signal Z and M values and SRIDs.
+const (
+	ewkbZ    uint32 = 0x80000000
+	ewkbM    uint32 = 0x40000000
+	ewkbSRID uint32 = 0x20000000
+)
+
+// MAX_WKB_DEPTH is the maximum nesting depth of geometry collections that will be decoded.
+const MAX_WKB_DEPTH int = 32
+
+// decodeGeoPackageBinary returns the `orb.Geometry` representation of the GeoPackage binary geometry 'buf'. It
+// returns nil for empty geometries.
+func decodeGeoPackageBinary(buf []byte) (orb.Geometry, error) {
+
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("Geometry too short")
+	}
+
+	if buf[0] != 'G' || buf[1] != 'P' {
+		return nil, fmt.Errorf("Invalid magic bytes, not a GeoPackage binary geometry")
+	}
+
+	if buf[2] != 0 {
+		return nil, fmt.Errorf("Unsupported GeoPackage binary version %d", buf[2])
+	}
+
+	flags := buf[3]
+
+	if flags&0x20 != 0 {
+		return nil, fmt.Errorf("Extended GeoPackage binary geometries are not supported")
+	}
+
+	if flags&0x10 != 0 {
+		return nil, nil
+	}
+
+	var envelope_size int
+
+	switch (flags >> 1) & 0x07 {
+	case 0:
+		envelope_size = 0
+	case 1:
+		envelope_size = 32
+	case 2, 3:
+		envelope_size = 48
+	case 4:
+		envelope_size = 64
+	default:
+		return nil, fmt.Errorf("Invalid envelope contents indicator %d", (flags>>1)&0x07)
+	}
+
+	offset := 8 + envelope_size
+
+	if offset > len(buf) {
+		return nil, fmt.Errorf("Geometry too short for envelope")
+	}
+
+	d := &wkbDecoder{buf: buf[offset:]}
+	return d.decode(0)
+}
+
+// wkbDecoder decodes (ISO or extended) WKB encoded geometries. Z and M values are read but discarded.
+type wkbDecoder struct {
+	buf    []byte
+	offset int
+}
+
+func (d *wkbDecoder) read(size int) ([]byte, error) {
+
+	if d.offset+size > len(d.buf) {
+		return nil, fmt.Errorf("Unexpected end of WKB")
+	}
+
+	b := d.buf[d.offset : d.offset+size]
+	d.offset += size
+
+	return b, nil
+}
+
+// decode decodes the next geometry, including its byte order and type header.
+func (d *wkbDecoder) decode(depth int) (orb.Geometry, error) {
+
+	if depth > MAX_WKB_DEPTH {
+		return nil, fmt.Errorf("Geometry collections nested too deeply")
+	}
+
+	b, err := d.read(5)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+
+	switch b[0] {
+	case 0:
+		order = binary.BigEndian
+	case 1:
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("Invalid byte order %d", b[0])
+	}
+
+	typ := order.Uint32(b[1:5])
+	dims := 2
+
+	if typ&ewkbZ != 0 {
+		dims += 1
+	}
+
+	if typ&ewkbM != 0 {
+		dims += 1
+	}
+
+	if typ&ewkbSRID != 0 {
+
+		_, err := d.read(4)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	typ = typ &^ (ewkbZ | ewkbM | ewkbSRID)
+
+	switch typ / 1000 {
+	case 0:
+		// pass
+	case 1, 2:
+		dims += 1
+	case 3:
+		dims += 2
+	default:
+		return nil, fmt.Errorf("Unsupported geometry type %d", typ)
+	}
+
+	typ = typ % 1000
+
+	switch typ {
+	case wkbPoint:
+
+		pt, err := d.point(order, dims)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Empty points are encoded with NaN coordinates
+		if math.IsNaN(pt[0]) && math.IsNaN(pt[1]) {
+			return nil, nil
+		}
+
+		return pt, nil
+
+	case wkbLineString:
+
+		pts, err := d.points(order, dims)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return orb.LineString(pts), nil
+
+	case wkbPolygon:
+		return d.polygon(order, dims)
+
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+
+		count, err := d.count(order)
+
+		if err != nil {
+			return nil, err
+		}
+
+		geoms := make([]orb.Geometry, 0)
+
+		for i := 0; i < count; i++ {
+
+			g, err := d.decode(depth + 1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			geoms = append(geoms, g)
+		}
+
+		return collect(typ, geoms)
+
+	default:
+		return nil, fmt.Errorf("Unsupported geometry type %d", typ)
+	}
+}
+
+// collect assembles 'geoms' in to a multi-geometry (or geometry collection) of type 'typ'.
+func collect(typ uint32, geoms []orb.Geometry) (orb.Geometry, error) {
+
+	switch typ {
+	case wkbMultiPoint:
+
+		mp := make(orb.MultiPoint, 0, len(geoms))
+
+		for _, g := range geoms {
+
+			// Empty points are decoded as nil
+			if g == nil {
+				continue
+			}
+
+			pt, ok := g.(orb.Point)
+
+			if !ok {
+				return nil, fmt.Errorf("Invalid multipoint member %T", g)
+			}
+
+			mp = append(mp, pt)
+		}
+
+		return mp, nil
+
+	case wkbMultiLineString:
+
+		mls := make(orb.MultiLineString, 0, len(geoms))
+
+		for _, g := range geoms {
+
+			ls, ok := g.(orb.LineString)
+
+			if !ok {
+				return nil, fmt.Errorf("Invalid multilinestring member %T", g)
+			}
+
+			mls = append(mls, ls)
+		}
+
+		return mls, nil
+
+	case wkbMultiPolygon:
+
+		mp := make(orb.MultiPolygon, 0, len(geoms))
+
+		for _, g := range geoms {
+
+			p, ok := g.(orb.Polygon)
+
+			if !ok {
+				return nil, fmt.Errorf("Invalid multipolygon member %T", g)
+			}
+
+			mp = append(mp, p)
+		}
+
+		return mp, nil
+
+	default:
+
+		c := make(orb.Collection, 0, len(geoms))
+
+		for _, g := range geoms {
+
+			if g != nil {
+				c = append(c, g)
+			}
+		}
+
+		return c, nil
+	}
+}
+
+// count reads a uint32 count, ensuring that it is not larger than the number of bytes remaining.
+func (d *wkbDecoder) count(order binary.ByteOrder) (int, error) {
+
+	b, err := d.read(4)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n := int64(order.Uint32(b))
+
+	if n > int64(len(d.buf)-d.offset) {
+		return 0, fmt.Errorf("Invalid count %d", n)
+	}
+
+	return int(n), nil
+}
+
+func (d *wkbDecoder) point(order binary.ByteOrder, dims int) (orb.Point, error) {
+
+	b, err := d.read(dims * 8)
+
+	if err != nil {
+		return orb.Point{}, err
+	}
+
+	x := math.Float64frombits(order.Uint64(b[0:8]))
+	y := math.Float64frombits(order.Uint64(b[8:16]))
+
+	return orb.Point{x, y}, nil
+}
+
+func (d *wkbDecoder) points(order binary.ByteOrder, dims int) ([]orb.Point, error) {
+
+	count, err := d.count(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pts := make([]orb.Point, 0, count)
+
+	for i := 0; i < count; i++ {
+
+		pt, err := d.point(order, dims)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pts = append(pts, pt)
+	}
+
+	return pts, nil
+}
+
+func (d *wkbDecoder) polygon(order binary.ByteOrder, dims int) (orb.Polygon, error) {
+
+	count, err := d.count(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poly := make(orb.Polygon, 0, count)
+
+	for i := 0; i < count; i++ {
+
+		pts, err := d.points(order, dims)
+
+		if err != nil {
+			return nil, err
+		}
+
+		poly = append(poly, orb.Ring(pts))
+	}
+
+	return poly, nil
+}
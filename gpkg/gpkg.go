@@ -0,0 +1,502 @@
+package gpkg
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/internal/bbox"
+)
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "gpkg", NewGeoPackageIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GeoPackageIterator implements the `Iterator` interface for crawling the rows of OGC GeoPackage feature tables as GeoJSON Features.
+type GeoPackageIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// table is the name of the feature table to crawl. If empty all the feature tables are crawled.
+	table string
+	// bbox is an optional bounding box that features must intersect in order to be crawled.
+	bbox *orb.Bound
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// featureTable is a feature table defined in the `gpkg_contents` table of a GeoPackage.
+type featureTable struct {
+	name string
+	// geometry_column is the name of the table's geometry column.
+	geometry_column string
+	// primary_key is the name of the table's integer primary key column or "rowid" if it does not have one.
+	primary_key string
+	// columns are the table's attribute (non-geometry, non-primary key) columns.
+	columns []*tableColumn
+	// rtree is the name of the table's spatial index or an empty string if it does not have one.
+	rtree string
+}
+
+// tableColumn is the name and declared type of a column in a feature table.
+type tableColumn struct {
+	name string
+	typ  string
+}
+
+// NewGeoPackageIterator() returns a new `GeoPackageIterator` instance configured by 'uri' in the form of:
+//
+//	gpkg://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?table=` The name of the feature table to crawl. (Default is all the feature tables listed in the `gpkg_contents` table.)
+// * `?bbox=` An optional bounding box, in the form of "minx,miny,maxx,maxy", that features must intersect in order to be crawled.
+//
+// URIs passed to the `Iterate` method are expected to be the paths of GeoPackages which are opened read-only. Each row
+// is converted in to a GeoJSON Feature whose ID is the row's primary key and whose properties are the values of all the
+// other (non-geometry) columns. The `Path` property of each record is the URI of the GeoPackage followed by "#", the
+// name of the table, "/" and the row's primary key.
+//
+// If `?bbox=` is defined and a table has a spatial index (a `gpkg_rtree_index` extension table) then the index is
+// used to select only the rows whose envelopes intersect it. Otherwise every row is read and those whose geometries do
+// not intersect the bounding box are skipped.
+func NewGeoPackageIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	it := &GeoPackageIterator{
+		filters:   f,
+		table:     q.Get("table"),
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	if q.Has("bbox") {
+
+		b, err := bbox.Parse(q.Get("bbox"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'bbox' parameter, %w", err)
+		}
+
+		it.bbox = b
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *GeoPackageIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateDatabase(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateDatabase yields records for each row in the feature tables of the GeoPackage at 'uri'. It returns false if
+// 'yield' has signaled that iteration should stop.
+func (it *GeoPackageIterator) iterateDatabase(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	abs_path, err := filepath.Abs(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err))
+	}
+
+	dsn_u := url.URL{
+		Scheme:   "file",
+		Path:     abs_path,
+		RawQuery: "mode=ro",
+	}
+
+	db, err := sql.Open("sqlite3", dsn_u.String())
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	defer db.Close()
+
+	tables, err := featureTables(ctx, db)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to list feature tables in '%s', %w", uri, err))
+	}
+
+	if it.table != "" {
+
+		if !slices.Contains(tables, it.table) {
+			return yield(nil, fmt.Errorf("'%s' is not a feature table in '%s'", it.table, uri))
+		}
+
+		tables = []string{it.table}
+	}
+
+	for _, name := range tables {
+
+		t, err := describeTable(ctx, db, name)
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to describe table '%s' in '%s', %w", name, uri, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		if !it.iterateTable(ctx, db, uri, t, yield) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// iterateTable yields records for each row in the feature table 't'. It returns false if 'yield' has signaled that
+// iteration should stop.
+func (it *GeoPackageIterator) iterateTable(ctx context.Context, db *sql.DB, uri string, t *featureTable, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri, "table", t.name)
+
+	q, args := it.query(t)
+	logger.Debug("Query table", "query", q)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to query table '%s' in '%s', %w", t.name, uri, err))
+	}
+
+	defer rows.Close()
+
+	values := make([]any, 2+len(t.columns))
+	ptrs := make([]any, len(values))
+
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+
+		var fid int64
+		ptrs[0] = &fid
+
+		err := rows.Scan(ptrs...)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to scan row in table '%s' in '%s', %w", t.name, uri, err))
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		path := fmt.Sprintf("%s#%s/%d", uri, t.name, fid)
+
+		var geom orb.Geometry
+
+		switch v := values[1].(type) {
+		case nil:
+			// pass
+		case []byte:
+			geom, err = decodeGeoPackageBinary(v)
+		default:
+			err = fmt.Errorf("Unexpected geometry value %T", v)
+		}
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to decode geometry for '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		if it.bbox != nil {
+
+			if geom == nil || !geom.Bound().Intersects(*it.bbox) {
+				continue
+			}
+		}
+
+		f := geojson.NewFeature(geom)
+		f.ID = fid
+
+		for i, c := range t.columns {
+			f.Properties[c.name] = propertyValue(c, values[2+i])
+		}
+
+		body, err := f.MarshalJSON()
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to marshal '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		br := bytes.NewReader(body)
+		rsc, err := ioutil.NewReadSeekCloser(br)
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		if it.filters != nil {
+
+			ok, err := iterate.ApplyFilters(ctx, rsc, it.filters)
+
+			if err != nil {
+				rsc.Close()
+
+				if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
+					return false
+				}
+
+				continue
+			}
+
+			if !ok {
+				rsc.Close()
+				continue
+			}
+		}
+
+		rec := iterate.NewRecord(path, rsc)
+
+		if !yield(rec, nil) {
+			return false
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to iterate rows in table '%s' in '%s', %w", t.name, uri, err))
+	}
+
+	return true
+}
+
+// query returns the SQL query, and its arguments, used to select rows from the feature table 't'.
+func (it *GeoPackageIterator) query(t *featureTable) (string, []any) {
+
+	cols := []string{
+		quoteIdentifier(t.primary_key),
+		quoteIdentifier(t.geometry_column),
+	}
+
+	for _, c := range t.columns {
+		cols = append(cols, quoteIdentifier(c.name))
+	}
+
+	q := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), quoteIdentifier(t.name))
+	args := make([]any, 0)
+
+	if it.bbox != nil && t.rtree != "" {
+
+		q = fmt.Sprintf("%s WHERE %s IN (SELECT id FROM %s WHERE minx <= ? AND maxx >= ? AND miny <= ? AND maxy >= ?)", q, quoteIdentifier(t.primary_key), quoteIdentifier(t.rtree))
+		args = append(args, it.bbox.Max[0], it.bbox.Min[0], it.bbox.Max[1], it.bbox.Min[1])
+	}
+
+	q = fmt.Sprintf("%s ORDER BY %s", q, quoteIdentifier(t.primary_key))
+
+	return q, args
+}
+
+// featureTables returns the names of the feature tables listed in the `gpkg_contents` table of 'db'.
+func featureTables(ctx context.Context, db *sql.DB) ([]string, error) {
+
+	rows, err := db.QueryContext(ctx, "SELECT table_name FROM gpkg_contents WHERE data_type = 'features' ORDER BY table_name")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tables := make([]string, 0)
+
+	for rows.Next() {
+
+		var name string
+
+		err := rows.Scan(&name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, name)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// describeTable returns the geometry column, primary key, attribute columns and spatial index for the feature table 'name'.
+func describeTable(ctx context.Context, db *sql.DB, name string) (*featureTable, error) {
+
+	t := &featureTable{
+		name:        name,
+		primary_key: "rowid",
+		columns:     make([]*tableColumn, 0),
+	}
+
+	row := db.QueryRowContext(ctx, "SELECT column_name FROM gpkg_geometry_columns WHERE table_name = ?", name)
+
+	err := row.Scan(&t.geometry_column)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine geometry column, %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name, type, pk FROM pragma_table_info(?) ORDER BY cid", name)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve columns, %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var col_name string
+		var col_type string
+		var pk int
+
+		err := rows.Scan(&col_name, &col_type, &pk)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan column, %w", err)
+		}
+
+		if pk > 0 && strings.EqualFold(col_type, "INTEGER") {
+			t.primary_key = col_name
+			continue
+		}
+
+		if col_name == t.geometry_column {
+			continue
+		}
+
+		t.columns = append(t.columns, &tableColumn{name: col_name, typ: col_type})
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to iterate columns, %w", err)
+	}
+
+	rtree := fmt.Sprintf("rtree_%s_%s", name, t.geometry_column)
+
+	var count int
+
+	row = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", rtree)
+
+	err = row.Scan(&count)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine whether spatial index exists, %w", err)
+	}
+
+	if count > 0 {
+		t.rtree = rtree
+	}
+
+	return t, nil
+}
+
+// propertyValue returns the GeoJSON property value for 'v' read from column 'c'. Integer values in BOOLEAN columns
+// are returned as booleans.
+func propertyValue(c *tableColumn, v any) any {
+
+	i, ok := v.(int64)
+
+	if ok && strings.EqualFold(c.typ, "BOOLEAN") {
+		return i != 0
+	}
+
+	return v
+}
+
+// quoteIdentifier returns 'name' quoted for use as an SQL identifier.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *GeoPackageIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *GeoPackageIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *GeoPackageIterator) Close() error {
+	return nil
+}
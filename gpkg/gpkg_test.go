@@ -0,0 +1,335 @@
+package gpkg
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+// encodeGeoPackageBinary returns the GeoPackage binary encoding, with an XY envelope, of 'geom'.
+func encodeGeoPackageBinary(t *testing.T, geom orb.Geometry) []byte {
+
+	t.Helper()
+
+	enc, err := wkb.Marshal(geom, binary.LittleEndian)
+
+	if err != nil {
+		t.Fatalf("Failed to encode geometry, %v", err)
+	}
+
+	return geoPackageBinary(geom.Bound(), enc)
+}
+
+// geoPackageBinary returns the GeoPackage binary encoding, with an XY envelope, of the WKB geometry 'enc'.
+func geoPackageBinary(bbox orb.Bound, enc []byte) []byte {
+
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{'G', 'P', 0x00, 0x03})
+	binary.Write(buf, binary.LittleEndian, int32(4326))
+
+	for _, v := range []float64{bbox.Min[0], bbox.Max[0], bbox.Min[1], bbox.Max[1]} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+
+	buf.Write(enc)
+
+	return buf.Bytes()
+}
+
+// newTestGeoPackage creates a new GeoPackage in a temporary directory containing a "places" feature table, with a
+// spatial index, of twenty points along the line x=y (and one point with a Z value), a "regions" feature table, without
+// a spatial index, containing a polygon with a hole and an "attributes" table which is not a feature table.
+func newTestGeoPackage(t *testing.T) string {
+
+	t.Helper()
+
+	db_path := filepath.Join(t.TempDir(), "test.gpkg")
+
+	db, err := sql.Open("sqlite3", db_path)
+
+	if err != nil {
+		t.Fatalf("Failed to open database, %v", err)
+	}
+
+	defer db.Close()
+
+	statements := []string{
+		"CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, identifier TEXT, srs_id INTEGER)",
+		"CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL, geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL)",
+		"CREATE TABLE places (fid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, geom POINT, name TEXT, population INTEGER, active BOOLEAN)",
+		"CREATE VIRTUAL TABLE rtree_places_geom USING rtree(id, minx, maxx, miny, maxy)",
+		"CREATE TABLE regions (fid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, shape POLYGON, name TEXT)",
+		"CREATE TABLE attributes (fid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, name TEXT)",
+		"INSERT INTO gpkg_contents (table_name, data_type, identifier, srs_id) VALUES ('places', 'features', 'places', 4326)",
+		"INSERT INTO gpkg_contents (table_name, data_type, identifier, srs_id) VALUES ('regions', 'features', 'regions', 4326)",
+		"INSERT INTO gpkg_contents (table_name, data_type, identifier, srs_id) VALUES ('attributes', 'attributes', 'attributes', 0)",
+		"INSERT INTO gpkg_geometry_columns VALUES ('places', 'geom', 'POINT', 4326, 2, 0)",
+		"INSERT INTO gpkg_geometry_columns VALUES ('regions', 'shape', 'POLYGON', 4326, 0, 0)",
+		"INSERT INTO attributes (name) VALUES ('example')",
+	}
+
+	for _, q := range statements {
+
+		_, err := db.Exec(q)
+
+		if err != nil {
+			t.Fatalf("Failed to execute '%s', %v", q, err)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+
+		pt := orb.Point{float64(i), float64(i)}
+		geom := encodeGeoPackageBinary(t, pt)
+
+		// Encode the last point with a Z value (ISO WKB "Point Z")
+		if i == 19 {
+
+			enc := new(bytes.Buffer)
+			enc.WriteByte(0x01)
+			binary.Write(enc, binary.LittleEndian, uint32(1001))
+
+			for _, v := range []float64{pt[0], pt[1], 100} {
+				binary.Write(enc, binary.LittleEndian, math.Float64bits(v))
+			}
+
+			geom = geoPackageBinary(pt.Bound(), enc.Bytes())
+		}
+
+		fid := int64(i + 1)
+
+		_, err := db.Exec("INSERT INTO places (fid, geom, name, population, active) VALUES (?, ?, ?, ?, ?)", fid, geom, fmt.Sprintf("place %d", i), i*1000, i%2)
+
+		if err != nil {
+			t.Fatalf("Failed to insert place, %v", err)
+		}
+
+		_, err = db.Exec("INSERT INTO rtree_places_geom (id, minx, maxx, miny, maxy) VALUES (?, ?, ?, ?, ?)", fid, pt[0], pt[0], pt[1], pt[1])
+
+		if err != nil {
+			t.Fatalf("Failed to insert index entry, %v", err)
+		}
+	}
+
+	poly := orb.Polygon{
+		orb.Ring{{100, 100}, {110, 100}, {110, 110}, {100, 110}, {100, 100}},
+		orb.Ring{{102, 102}, {102, 104}, {104, 104}, {104, 102}, {102, 102}},
+	}
+
+	_, err = db.Exec("INSERT INTO regions (shape, name) VALUES (?, ?)", encodeGeoPackageBinary(t, poly), "region")
+
+	if err != nil {
+		t.Fatalf("Failed to insert region, %v", err)
+	}
+
+	return db_path
+}
+
+type testFeature struct {
+	Type       string         `json:"type"`
+	Id         int64          `json:"id"`
+	Properties map[string]any `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func TestGeoPackageIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	db_path := newTestGeoPackage(t)
+
+	tests := map[string]int{
+		"gpkg://":                                               21,
+		"gpkg://?table=places":                                  20,
+		"gpkg://?table=regions":                                 1,
+		"gpkg://?table=places&bbox=0,0,4.5,4.5":                 5,
+		"gpkg://?bbox=18.5,18.5,200,200":                        2,
+		"gpkg://?table=regions&bbox=0,0,4.5,4.5":                0,
+		"gpkg://?include=properties.name=^place%201":            11,
+		"gpkg://?table=places&exclude=properties.active=true":   10,
+		"gpkg://?table=places&bbox=0,0,4.5,4.5&_max_procs=1":    5,
+		"gpkg://?table=places&include=properties.population=^0": 1,
+	}
+
+	for iter_uri, expected := range tests {
+
+		it, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new gpkg source for '%s', %v", iter_uri, err)
+		}
+
+		count := 0
+
+		for rec, err := range it.Iterate(ctx, db_path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' with '%s', %v", db_path, iter_uri, err)
+			}
+
+			defer rec.Body.Close()
+
+			body, err := io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			var f testFeature
+
+			err = json.Unmarshal(body, &f)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal %s, %v", rec.Path, err)
+			}
+
+			if f.Type != "Feature" || f.Geometry == nil {
+				t.Fatalf("Invalid feature for %s, %s", rec.Path, string(body))
+			}
+
+			_, has_geom := f.Properties["geom"]
+			_, has_fid := f.Properties["fid"]
+
+			if has_geom || has_fid {
+				t.Fatalf("Unexpected properties for %s, %v", rec.Path, f.Properties)
+			}
+
+			switch f.Properties["name"] {
+			case "region":
+
+				expected_path := fmt.Sprintf("%s#regions/%d", db_path, f.Id)
+
+				if rec.Path != expected_path {
+					t.Fatalf("Unexpected path. Got %s but expected %s", rec.Path, expected_path)
+				}
+
+				var coords [][][]float64
+
+				err := json.Unmarshal(f.Geometry.Coordinates, &coords)
+
+				if err != nil {
+					t.Fatalf("Failed to unmarshal coordinates for %s, %v", rec.Path, err)
+				}
+
+				if f.Geometry.Type != "Polygon" || len(coords) != 2 {
+					t.Fatalf("Expected polygon with interior ring for %s, %s", rec.Path, string(body))
+				}
+
+			default:
+
+				expected_path := fmt.Sprintf("%s#places/%d", db_path, f.Id)
+
+				if rec.Path != expected_path {
+					t.Fatalf("Unexpected path. Got %s but expected %s", rec.Path, expected_path)
+				}
+
+				var coords []float64
+
+				err := json.Unmarshal(f.Geometry.Coordinates, &coords)
+
+				if err != nil {
+					t.Fatalf("Failed to unmarshal coordinates for %s, %v", rec.Path, err)
+				}
+
+				i := float64(f.Id - 1)
+
+				if f.Geometry.Type != "Point" || len(coords) != 2 || coords[0] != i || coords[1] != i {
+					t.Fatalf("Unexpected geometry for %s, %s", rec.Path, string(body))
+				}
+
+				if f.Properties["active"] != (int(i)%2 == 1) {
+					t.Fatalf("Unexpected active property for %s, %v", rec.Path, f.Properties["active"])
+				}
+			}
+
+			count += 1
+		}
+
+		if count != expected {
+			t.Fatalf("Unexpected record count for '%s'. Got %d but expected %d", iter_uri, count, expected)
+		}
+	}
+}
+
+func TestGeoPackageIteratorIndex(t *testing.T) {
+
+	ctx := context.Background()
+
+	db_path := newTestGeoPackage(t)
+
+	// Use the "raw" iterator to check how many rows were actually read
+
+	tests := map[string]int64{
+		// Spatial index
+		"gpkg://?table=places&bbox=0,0,4.5,4.5": 5,
+		// No spatial index
+		"gpkg://?table=regions&bbox=0,0,4.5,4.5": 1,
+	}
+
+	for iter_uri, expected := range tests {
+
+		it, err := NewGeoPackageIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new gpkg source for '%s', %v", iter_uri, err)
+		}
+
+		for rec, err := range it.Iterate(ctx, db_path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' with '%s', %v", db_path, iter_uri, err)
+			}
+
+			rec.Body.Close()
+		}
+
+		seen := it.Seen()
+
+		if seen != expected {
+			t.Fatalf("Unexpected number of rows read for '%s'. Got %d but expected %d", iter_uri, seen, expected)
+		}
+	}
+}
+
+func TestGeoPackageIteratorInvalidTable(t *testing.T) {
+
+	ctx := context.Background()
+
+	db_path := newTestGeoPackage(t)
+
+	for _, iter_uri := range []string{"gpkg://?table=attributes", "gpkg://?table=missing"} {
+
+		it, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new gpkg source for '%s', %v", iter_uri, err)
+		}
+
+		failed := false
+
+		for _, err := range it.Iterate(ctx, db_path) {
+
+			if err != nil {
+				failed = true
+			}
+		}
+
+		if !failed {
+			t.Fatalf("Expected '%s' to fail", iter_uri)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+
+	ctx := context.Background()
+
+	for _, scheme := range []string{"http", "https"} {
+
+		err := RegisterIterator(ctx, scheme, NewHTTPIterator)
+
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// HTTPIterator implements the `Iterator` interface for crawling individual records fetched from remote (http:// or https://) URIs.
+type HTTPIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// http_options is a `HTTPOptions` instance used to configure how remote records are fetched.
+	http_options *HTTPOptions
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewHTTPIterator() returns a new `HTTPIterator` instance configured by 'uri' in the form of:
+//
+//	http://?{PARAMETERS}
+//	https://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?access_token=` An optional bearer token to include with each request.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with each request.
+// * `?max_resume=` The maximum number of times to resume reading a record, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+//
+// URIs passed to the `Iterate` method are expected to be the URLs of individual records. Failed requests are reported as
+// errors and can be retried using the `_retry` parameter.
+func NewHTTPIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	http_options, err := NewHTTPOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive HTTP options from query, %w", err)
+	}
+
+	it := &HTTPIterator{
+		filters:      f,
+		http_options: http_options,
+		seen:         int64(0),
+		iterating:    new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *HTTPIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			logger_uri, err := ScrubURI(uri)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to scrub URI, %w", err)) {
+					return
+				}
+
+				continue
+			}
+
+			r, err := newHTTPReader(ctx, uri, it.http_options)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", logger_uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			// Individual records are assumed to be small enough to buffer in memory which
+			// saves having to fetch them a second time after the filters have been applied.
+
+			body, err := io.ReadAll(r)
+			r.Close()
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to read '%s', %w", logger_uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			atomic.AddInt64(&it.seen, 1)
+
+			br := bytes.NewReader(body)
+			rsc, err := ioutil.NewReadSeekCloser(br)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", logger_uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			if it.filters != nil {
+
+				ok, err := ApplyFilters(ctx, rsc, it.filters)
+
+				if err != nil {
+					rsc.Close()
+					if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", logger_uri, err)) {
+						return
+					}
+
+					continue
+				}
+
+				if !ok {
+					rsc.Close()
+					continue
+				}
+			}
+
+			rec := NewRecord(uri, rsc)
+
+			if !yield(rec, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *HTTPIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *HTTPIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *HTTPIterator) Close() error {
+	return nil
+}
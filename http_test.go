@@ -0,0 +1,339 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPIterator(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	fs_handler := http.FileServer(http.Dir("fixtures"))
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("X-Api-Key") != "s33kret" {
+			http.Error(rsp, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		fs_handler.ServeHTTP(rsp, req)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	uris := []string{
+		s.URL + "/data/174/657/420/7/1746574207.geojson",
+		s.URL + "/data/174/612/434/7/1746124347.geojson",
+		s.URL + "/data/174/616/026/9/1746160269.geojson",
+	}
+
+	q := url.Values{}
+	q.Set("header", "X-Api-Key:s33kret")
+
+	iter_uri := fmt.Sprintf("http://?%s", q.Encode())
+
+	it, err := NewIterator(ctx, iter_uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create new http source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, uris...) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		defer rec.Body.Close()
+		_, err = io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		_, err = rec.Body.Seek(0, 0)
+
+		if err != nil {
+			t.Fatalf("Failed to rewind body for %s, %v", rec.Path, err)
+		}
+
+		_, err = io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed second read body for %s, %v", rec.Path, err)
+		}
+	}
+
+	seen := it.Seen()
+	expected := int64(3)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+
+	// Without the header all the requests should fail
+
+	it, err = NewIterator(ctx, "http://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new http source, %v", err)
+	}
+
+	for _, err := range it.Iterate(ctx, uris[0]) {
+
+		if err == nil {
+			t.Fatalf("Expected request without header to fail")
+		}
+	}
+}
+
+func TestHTTPIteratorRetry(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	fs_handler := http.FileServer(http.Dir("fixtures"))
+
+	requests := int64(0)
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if atomic.AddInt64(&requests, 1) == 1 {
+			http.Error(rsp, "Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		fs_handler.ServeHTTP(rsp, req)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	uri := s.URL + "/data/174/657/420/7/1746574207.geojson"
+
+	it, err := NewIterator(ctx, "https://?_retry=true&_max_retries=2&_retry_after=1")
+
+	if err != nil {
+		t.Fatalf("Failed to create new http source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, uri) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(1)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+}
+
+func TestGeoJSONLIteratorHTTP(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	fs_handler := http.FileServer(http.Dir("fixtures"))
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("Authorization") != "Bearer s33kret" {
+			http.Error(rsp, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		fs_handler.ServeHTTP(rsp, req)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	uri := s.URL + "/collection.geojsonl"
+
+	it, err := NewIterator(ctx, "geojsonl://?access_token=s33kret")
+
+	if err != nil {
+		t.Fatalf("Failed to create new geojsonl source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, uri) {
+
+		if err != nil {
+			t.Fatalf("Failed to walk '%s', %v", uri, err)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(2)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+}
+
+func TestFeatureCollectionIteratorHTTP(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	s := httptest.NewServer(http.FileServer(http.Dir("fixtures")))
+	defer s.Close()
+
+	uri := s.URL + "/collection.geojson"
+
+	it, err := NewIterator(ctx, "featurecollection://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new featurecollection source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, uri) {
+
+		if err != nil {
+			t.Fatalf("Failed to walk '%s', %v", uri, err)
+		}
+
+		rec.Body.Close()
+	}
+
+	seen := it.Seen()
+	expected := int64(2)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+}
+
+func TestHTTPReaderResume(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	body, err := os.ReadFile("fixtures/collection.geojsonl")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	modtime := time.Now()
+
+	handler := func(rsp http.ResponseWriter, req *http.Request) {
+
+		if req.Header.Get("Range") != "" {
+			http.ServeContent(rsp, req, "collection.geojsonl", modtime, bytes.NewReader(body))
+			return
+		}
+
+		// Drop the connection halfway through the response
+
+		rsp.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+		rsp.WriteHeader(http.StatusOK)
+
+		rsp.Write(body[0 : len(body)/2])
+		rsp.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	opts := &HTTPOptions{
+		MaxResume: 1,
+	}
+
+	r, err := newHTTPReader(ctx, s.URL+"/collection.geojsonl", opts)
+
+	if err != nil {
+		t.Fatalf("Failed to create HTTP reader, %v", err)
+	}
+
+	defer r.Close()
+
+	resumed, err := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Failed to read body, %v", err)
+	}
+
+	if !bytes.Equal(resumed, body) {
+		t.Fatalf("Resumed body does not match original")
+	}
+
+	_, err = r.Seek(-10, io.SeekEnd)
+
+	if err != nil {
+		t.Fatalf("Failed to seek, %v", err)
+	}
+
+	tail, err := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Failed to read tail, %v", err)
+	}
+
+	if !bytes.Equal(tail, body[len(body)-10:]) {
+		t.Fatalf("Unexpected tail '%s'", tail)
+	}
+
+	// Without any resume attempts the interrupted read should fail
+
+	opts.MaxResume = 0
+
+	r2, err := newHTTPReader(ctx, s.URL+"/collection.geojsonl", opts)
+
+	if err != nil {
+		t.Fatalf("Failed to create HTTP reader, %v", err)
+	}
+
+	defer r2.Close()
+
+	_, err = io.ReadAll(r2)
+
+	if err == nil || !strings.Contains(err.Error(), "Failed to read body") {
+		t.Fatalf("Expected interrupted read to fail, got %v", err)
+	}
+}
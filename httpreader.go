@@ -0,0 +1,329 @@
+package iterate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTPOptions defines configuration options for reading remote (http:// or https://) files.
+type HTTPOptions struct {
+	// Client is the `http.Client` instance used to issue requests. If nil `http.DefaultClient` is used.
+	Client *http.Client
+	// Headers are additional HTTP headers to include with each request.
+	Headers http.Header
+	// MaxResume is the maximum number of times to resume reading a file, using HTTP range requests, if a connection is interrupted.
+	MaxResume int
+}
+
+// NewHTTPOptionsFromQuery returns a new `HTTPOptions` instance derived from 'q' which is expected to contain
+// zero or more of the following parameters:
+// * `?access_token=` An optional bearer token to include with each request.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with each request.
+// * `?max_resume=` The maximum number of times to resume reading a file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+func NewHTTPOptionsFromQuery(ctx context.Context, q url.Values) (*HTTPOptions, error) {
+
+	headers := make(http.Header)
+
+	for _, h := range q["header"] {
+
+		k, v, ok := strings.Cut(h, ":")
+
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("Invalid 'header' parameter, expected {NAME}:{VALUE}")
+		}
+
+		headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+
+	if q.Has("access_token") {
+		headers.Set("Authorization", fmt.Sprintf("Bearer %s", q.Get("access_token")))
+	}
+
+	max_resume := 3
+
+	if q.Has("max_resume") {
+
+		v, err := strconv.Atoi(q.Get("max_resume"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'max_resume' parameter, %w", err)
+		}
+
+		max_resume = v
+	}
+
+	opts := &HTTPOptions{
+		Headers:   headers,
+		MaxResume: max_resume,
+	}
+
+	return opts, nil
+}
+
+// isRemotePath returns a boolean value indicating whether 'path' is a remote (http:// or https://) URI.
+func isRemotePath(path string) bool {
+
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// httpReader implements the `io.ReadSeekCloser` interface for streaming the body of a remote file. Seeking, and
+// resuming interrupted reads, is accomplished by issuing new requests using the HTTP "Range" header. If a server
+// does not support range requests the body is read from the beginning and any bytes before the desired offset are
+// discarded.
+type httpReader struct {
+	ctx        context.Context
+	uri        string
+	client     *http.Client
+	headers    http.Header
+	max_resume int
+	body       io.ReadCloser
+	offset     int64
+	size       int64
+}
+
+// newHTTPReader returns a new `httpReader` instance for 'uri'. No requests are issued until the first read.
+func newHTTPReader(ctx context.Context, uri string, opts *HTTPOptions) (*httpReader, error) {
+
+	_, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	r := &httpReader{
+		ctx:    ctx,
+		uri:    uri,
+		client: http.DefaultClient,
+		size:   -1,
+	}
+
+	if opts != nil {
+
+		if opts.Client != nil {
+			r.client = opts.Client
+		}
+
+		r.headers = opts.Headers
+		r.max_resume = opts.MaxResume
+	}
+
+	return r, nil
+}
+
+// Read implements the `io.Reader` interface.
+func (r *httpReader) Read(p []byte) (int, error) {
+
+	attempts := 0
+
+	for {
+
+		if r.body == nil {
+
+			err := r.open()
+
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+
+		if err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		r.body.Close()
+		r.body = nil
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if attempts >= r.max_resume || r.ctx.Err() != nil {
+			return 0, fmt.Errorf("Failed to read body, %w", err)
+		}
+
+		attempts += 1
+
+		logger_uri, _ := ScrubURI(r.uri)
+		slog.Debug("Resume interrupted read", "uri", logger_uri, "offset", r.offset, "attempt", attempts, "error", err)
+	}
+}
+
+// Seek implements the `io.Seeker` interface.
+func (r *httpReader) Seek(offset int64, whence int) (int64, error) {
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+
+		if r.size < 0 {
+
+			err := r.head()
+
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		abs = r.size + offset
+
+	default:
+		return 0, fmt.Errorf("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, fmt.Errorf("Negative position")
+	}
+
+	if abs == r.offset {
+		return abs, nil
+	}
+
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// Close implements the `io.Closer` interface.
+func (r *httpReader) Close() error {
+
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	return nil
+}
+
+// newRequest returns a new `http.Request` instance for the reader's URI.
+func (r *httpReader) newRequest(method string) (*http.Request, error) {
+
+	req, err := http.NewRequestWithContext(r.ctx, method, r.uri, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request, %w", err)
+	}
+
+	for k, values := range r.headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	// Ensure that offsets always refer to the bytes on the wire (and prevent the transport
+	// from transparently decompressing responses that have been encoded by the server).
+	req.Header.Set("Accept-Encoding", "identity")
+
+	return req, nil
+}
+
+// open issues a GET request for the reader's URI starting at the current offset.
+func (r *httpReader) open() error {
+
+	req, err := r.newRequest(http.MethodGet)
+
+	if err != nil {
+		return err
+	}
+
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+
+	rsp, err := r.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("Failed to execute request, %w", err)
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+
+		r.size = rsp.ContentLength
+
+		if r.offset > 0 {
+
+			// The server does not support range requests so discard everything before the current offset
+
+			_, err := io.CopyN(io.Discard, rsp.Body, r.offset)
+
+			if err != nil {
+				rsp.Body.Close()
+				return fmt.Errorf("Failed to advance to offset %d, %w", r.offset, err)
+			}
+		}
+
+	case http.StatusPartialContent:
+
+		_, total, ok := strings.Cut(rsp.Header.Get("Content-Range"), "/")
+
+		if ok {
+
+			v, err := strconv.ParseInt(total, 10, 64)
+
+			if err == nil {
+				r.size = v
+			}
+		}
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		rsp.Body.Close()
+		r.body = io.NopCloser(strings.NewReader(""))
+		return nil
+
+	default:
+		rsp.Body.Close()
+		return fmt.Errorf("Unexpected HTTP status %s", rsp.Status)
+	}
+
+	r.body = rsp.Body
+	return nil
+}
+
+// head issues a HEAD request for the reader's URI in order to determine its size.
+func (r *httpReader) head() error {
+
+	req, err := r.newRequest(http.MethodHead)
+
+	if err != nil {
+		return err
+	}
+
+	rsp, err := r.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("Failed to execute request, %w", err)
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected HTTP status %s", rsp.Status)
+	}
+
+	if rsp.ContentLength < 0 {
+		return fmt.Errorf("Unable to determine size of remote file")
+	}
+
+	r.size = rsp.ContentLength
+	return nil
+}
@@ -0,0 +1,286 @@
+package iterate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/internal/relpath"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "ids", NewIdsIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IdsIterator implements the `Iterator` interface for crawling Who's On First records listed, by ID, in one or more
+// files and resolved against one or more data directories.
+type IdsIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how lists of IDs are read.
+	reader_options *ReaderOptions
+	// roots are the (absolute) data directories that IDs are resolved against, in the order they are searched.
+	roots []string
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewIdsIterator() returns a new `IdsIterator` instance configured by 'uri' in the form of:
+//
+//	ids://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?root=` One or more Who's On First data directories that IDs are resolved against, searched in the order they are defined. (Required.)
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each list of IDs. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) lists of IDs.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote lists of IDs.
+//
+// Each URI passed to the `Iterate` method is a file (or `STDIN`) containing one Who's On First ID per line, optionally followed
+// by an alternate geometry label separated by whitespace or a comma. Blank lines and lines starting with "#" are ignored. IDs
+// which can not be found in any of the data directories are yielded as `NotFoundError` errors and iteration continues.
+func NewIdsIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	roots := make([]string, 0)
+
+	for _, root := range q["root"] {
+
+		abs_root, err := filepath.Abs(root)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive absolute path for root '%s', %w", root, err)
+		}
+
+		roots = append(roots, abs_root)
+	}
+
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("No data directories defined, missing 'root' parameter")
+	}
+
+	it := &IdsIterator{
+		filters:        f,
+		reader_options: reader_options,
+		roots:          roots,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record listed in 'uris'.
+func (it *IdsIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateList(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateList yields a record for each ID listed in 'uri'. It returns false if iteration should stop.
+func (it *IdsIterator) iterateList(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	abs_path := uri
+
+	if uri != STDIN && !isRemotePath(uri) {
+
+		v, err := filepath.Abs(uri)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err))
+		}
+
+		abs_path = v
+	}
+
+	r, err := ReaderWithPathAndOptions(ctx, abs_path, it.reader_options)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", abs_path, err))
+	}
+
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	ln := 0
+
+	for scanner.Scan() {
+
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		ln += 1
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		source := fmt.Sprintf("%s#%d", uri, ln)
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+
+		if len(fields) > 2 {
+			if !yield(nil, fmt.Errorf("Invalid line '%s' in '%s', expected an ID and an optional alt label", line, source)) {
+				return false
+			}
+
+			continue
+		}
+
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+
+		if err != nil {
+			if !yield(nil, fmt.Errorf("Failed to parse ID '%s' in '%s', %w", fields[0], source, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		alt_label := ""
+
+		if len(fields) == 2 {
+			alt_label = fields[1]
+		}
+
+		if !it.yieldId(ctx, source, id, alt_label, yield) {
+			return false
+		}
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to read '%s', %w", uri, err))
+	}
+
+	return true
+}
+
+// yieldId resolves 'id' (and 'alt_label') against each data directory, in order, and yields the first matching record
+// or a `NotFoundError` if there is no match. It returns false if iteration should stop.
+func (it *IdsIterator) yieldId(ctx context.Context, source string, id int64, alt_label string, yield func(rec *Record, err error) bool) bool {
+
+	rel_path, err := relpath.FromId(id, alt_label)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to derive path for ID %d in '%s', %w", id, source, err))
+	}
+
+	for _, root := range it.roots {
+
+		path := filepath.Join(root, rel_path)
+
+		r, err := os.Open(path)
+
+		if err != nil {
+
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return yield(nil, fmt.Errorf("Failed to open '%s', %w", path, err))
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		if it.filters != nil {
+
+			ok, err := ApplyFilters(ctx, r, it.filters)
+
+			if err != nil {
+				r.Close()
+				return yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err))
+			}
+
+			if !ok {
+				r.Close()
+				return true
+			}
+		}
+
+		rec := NewRecord(path, r)
+		return yield(rec, nil)
+	}
+
+	not_found := &NotFoundError{
+		Id:       id,
+		AltLabel: alt_label,
+		Source:   source,
+		Roots:    it.roots,
+	}
+
+	return yield(nil, not_found)
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *IdsIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *IdsIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *IdsIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+package iterate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIdsIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	data_root, err := filepath.Abs("fixtures/data")
+
+	if err != nil {
+		t.Fatalf("Failed to derive absolute path for fixtures, %v", err)
+	}
+
+	// A second data directory, searched first, containing an alternate geometry and a copy of one of the fixtures
+
+	local_root := t.TempDir()
+
+	copies := map[string]string{
+		"174/612/434/7/1746124347.geojson":                   "174/612/434/7/1746124347.geojson",
+		"174/657/420/7/1746574207-alt-quattroshapes.geojson": "174/657/420/7/1746574207.geojson",
+	}
+
+	for rel_path, fixture := range copies {
+
+		body, err := os.ReadFile(filepath.Join(data_root, fixture))
+
+		if err != nil {
+			t.Fatalf("Failed to read fixture, %v", err)
+		}
+
+		path := filepath.Join(local_root, rel_path)
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory for %s, %v", path, err)
+		}
+
+		err = os.WriteFile(path, body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+	}
+
+	ids := "# Ticket 1234\n1746574207\n\n 1746124347 \n1746574207,quattroshapes\n1234\n1477881743\n"
+
+	list_path := filepath.Join(t.TempDir(), "ids.txt")
+
+	err = os.WriteFile(list_path, []byte(ids), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write list of IDs, %v", err)
+	}
+
+	q := url.Values{}
+	q.Add("root", local_root)
+	q.Add("root", data_root)
+
+	it, err := NewIterator(ctx, fmt.Sprintf("ids://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create new ids source, %v", err)
+	}
+
+	paths := make([]string, 0)
+	not_found := make([]*NotFoundError, 0)
+
+	for rec, err := range it.Iterate(ctx, list_path) {
+
+		if err != nil {
+
+			var nf *NotFoundError
+
+			if !errors.As(err, &nf) {
+				t.Fatalf("Unexpected error, %v", err)
+			}
+
+			not_found = append(not_found, nf)
+			continue
+		}
+
+		rec.Body.Close()
+		paths = append(paths, rec.Path)
+	}
+
+	expected := []string{
+		filepath.Join(data_root, "174/657/420/7/1746574207.geojson"),
+		filepath.Join(local_root, "174/612/434/7/1746124347.geojson"),
+		filepath.Join(local_root, "174/657/420/7/1746574207-alt-quattroshapes.geojson"),
+		filepath.Join(data_root, "147/788/174/3/1477881743.geojson"),
+	}
+
+	slices.Sort(paths)
+	slices.Sort(expected)
+
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("Unexpected paths. Got %v but expected %v", paths, expected)
+	}
+
+	if len(not_found) != 1 {
+		t.Fatalf("Unexpected not found count. Got %d but expected 1", len(not_found))
+	}
+
+	if not_found[0].Id != 1234 || not_found[0].Source != list_path+"#6" {
+		t.Fatalf("Unexpected not found error, %v", not_found[0])
+	}
+
+	if !IsNotFound(fmt.Errorf("Failed to resolve IDs, %w", not_found[0])) {
+		t.Fatalf("Expected wrapped error to be a not found error")
+	}
+}
+
+func TestIdsIteratorInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	it, err := NewIdsIterator(ctx, "ids://?root=fixtures/data")
+
+	if err != nil {
+		t.Fatalf("Failed to create new ids source, %v", err)
+	}
+
+	list_path := filepath.Join(t.TempDir(), "ids.txt")
+
+	err = os.WriteFile(list_path, []byte("SFO\n1746574207 a b\n1746574207\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write list of IDs, %v", err)
+	}
+
+	count := 0
+	errs := 0
+
+	for rec, err := range it.Iterate(ctx, list_path) {
+
+		if err != nil {
+
+			if IsNotFound(err) {
+				t.Fatalf("Unexpected not found error, %v", err)
+			}
+
+			errs += 1
+			continue
+		}
+
+		rec.Body.Close()
+		count += 1
+	}
+
+	if count != 1 || errs != 2 {
+		t.Fatalf("Unexpected results. Got %d records and %d errors but expected 1 and 2", count, errs)
+	}
+
+	// No data directories
+
+	_, err = NewIdsIterator(ctx, "ids://")
+
+	if err == nil {
+		t.Fatalf("Expected ids source without any data directories to fail")
+	}
+}
@@ -0,0 +1,44 @@
+// Package bbox provides methods for working with bounding boxes defined in iterator URIs.
+package bbox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// Parse parses 'str', in the form of "minx,miny,maxx,maxy", in to an `orb.Bound` instance.
+func Parse(str string) (*orb.Bound, error) {
+
+	parts := strings.Split(str, ",")
+
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("Invalid bounding box, expected minx,miny,maxx,maxy")
+	}
+
+	coords := make([]float64, 4)
+
+	for i, p := range parts {
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bounding box coordinate '%s', %w", p, err)
+		}
+
+		coords[i] = v
+	}
+
+	if coords[0] > coords[2] || coords[1] > coords[3] {
+		return nil, fmt.Errorf("Invalid bounding box, minimum coordinates exceed maximum coordinates")
+	}
+
+	bbox := &orb.Bound{
+		Min: orb.Point{coords[0], coords[1]},
+		Max: orb.Point{coords[2], coords[3]},
+	}
+
+	return bbox, nil
+}
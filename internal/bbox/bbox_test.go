@@ -0,0 +1,27 @@
+package bbox
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	b, err := Parse("-122.5, 37.6,-122.3,37.8")
+
+	if err != nil {
+		t.Fatalf("Failed to parse bounding box, %v", err)
+	}
+
+	if b.Min[0] != -122.5 || b.Min[1] != 37.6 || b.Max[0] != -122.3 || b.Max[1] != 37.8 {
+		t.Fatalf("Unexpected bounding box, %v", b)
+	}
+
+	for _, str := range []string{"", "1,2,3", "1,2,3,4,5", "a,2,3,4", "3,2,1,4", "1,4,3,2"} {
+
+		_, err := Parse(str)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Package relpath provides methods for deriving relative Who's On First paths.
+package relpath
+
+import (
+	"fmt"
+
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+// FromId returns the relative Who's On First path for 'id' and, if not empty, 'alt_label'.
+func FromId(id int64, alt_label string) (string, error) {
+
+	if alt_label == "" {
+		return uri.Id2RelPath(id)
+	}
+
+	args, err := uri.NewAlternateURIArgsFromAltLabel(alt_label)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to derive URI args from alt label '%s', %w", alt_label, err)
+	}
+
+	return uri.Id2RelPath(id, args)
+}
@@ -0,0 +1,27 @@
+package relpath
+
+import (
+	"testing"
+)
+
+func TestFromId(t *testing.T) {
+
+	tests := map[string]string{
+		"":                                "101/736/545/101736545.geojson",
+		"quattroshapes":                   "101/736/545/101736545-alt-quattroshapes.geojson",
+		"naturalearth-display-terse-010m": "101/736/545/101736545-alt-naturalearth-display-terse-010m.geojson",
+	}
+
+	for alt_label, expected := range tests {
+
+		path, err := FromId(101736545, alt_label)
+
+		if err != nil {
+			t.Fatalf("Failed to derive path for '%s', %v", alt_label, err)
+		}
+
+		if path != expected {
+			t.Fatalf("Unexpected path for '%s'. Got %s but expected %s", alt_label, path, expected)
+		}
+	}
+}
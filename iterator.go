@@ -46,6 +46,20 @@ func RegisterIterator(ctx context.Context, scheme string, f IteratorInitializati
 // 'uri' as specific to the package implementing the interface.
 func NewIterator(ctx context.Context, uri string) (Iterator, error) {
 
+	it, err := newUnwrappedIterator(ctx, uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConcurrentIterator(ctx, uri, it)
+}
+
+// newUnwrappedIterator() returns a new `Iterator` instance derived from 'uri' using the initialization function
+// registered for its scheme, without wrapping it in a `concurrentIterator` instance. This is used by iterators,
+// like `AutoIterator` and `OverlayIterator`, which manage their own child iterators.
+func newUnwrappedIterator(ctx context.Context, uri string) (Iterator, error) {
+
 	u, err := url.Parse(uri)
 
 	if err != nil {
@@ -80,13 +94,7 @@ func NewIterator(ctx context.Context, uri string) (Iterator, error) {
 		return nil, fmt.Errorf("Undefined initialization function")
 	}
 
-	it, err := fn(ctx, uri)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return NewConcurrentIterator(ctx, uri, it)
+	return fn(ctx, uri)
 }
 
 // IteratorSchemes() returns the list of schemes that have been "registered".
@@ -15,9 +15,24 @@ func TestNewIterator(t *testing.T) {
 
 	ctx := context.Background()
 
+	// Iterators which can not be created without specific parameters
+
+	uris := map[string]string{
+		"ids://":       "ids://?root=fixtures/data",
+		"gitdiff://":   "gitdiff://?from=HEAD",
+		"jsonpath://":  "jsonpath://?path=@this",
+		"jsonpathl://": "jsonpathl://?path=@this",
+	}
+
 	for _, s := range IteratorSchemes() {
 
-		it, err := NewIterator(ctx, s)
+		uri, ok := uris[s]
+
+		if !ok {
+			uri = s
+		}
+
+		it, err := NewIterator(ctx, uri)
 
 		if err != nil {
 			t.Fatalf("Failed to create new iterator for '%s', %v", s, err)
@@ -0,0 +1,267 @@
+package iterate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+
+	for _, scheme := range []string{"jsonpath", "jsonpathl"} {
+
+		err := RegisterIterator(ctx, scheme, NewJSONPathIterator)
+
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// JSONPathIterator implements the `Iterator` interface for crawling the elements of an array located at a given path
+// in JSON documents (or in each line of line-separated JSON documents).
+type JSONPathIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
+	// path is the `tidwall/gjson` path of the array whose elements will be crawled.
+	path string
+	// lines is a boolean value indicating whether each file contains line-separated JSON documents.
+	lines bool
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewJSONPathIterator() returns a new `JSONPathIterator` instance configured by 'uri' in the form of:
+//
+//	jsonpath://?path={PATH}&{PARAMETERS}
+//	jsonpathl://?path={PATH}&{PARAMETERS}
+//
+// Where {PATH} is a `tidwall/gjson` path (for example "data.items") of the array, in each document, whose elements
+// will be crawled. Use "@this" for documents whose top-level value is an array. The "jsonpath" scheme expects each
+// file to contain a single JSON document and the "jsonpathl" scheme expects each file to contain line-separated
+// (NDJSON) documents. And {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each file. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+//
+// The `Path` property of each record is the URI of the file followed by "#" and the (zero-based) index of the element.
+// For line-separated documents the index is counted across all the lines in the file.
+func NewJSONPathIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	path := q.Get("path")
+
+	if path == "" {
+		return nil, fmt.Errorf("Missing ?path= parameter")
+	}
+
+	it := &JSONPathIterator{
+		filters:        f,
+		reader_options: reader_options,
+		path:           path,
+		lines:          u.Scheme == "jsonpathl",
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *JSONPathIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateFile(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateFile yields records for each element of the array at the iterator's path in the file at 'uri'. It
+// returns false if 'yield' has signaled that iteration should stop.
+func (it *JSONPathIterator) iterateFile(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	r, err := ReaderWithPathAndOptions(ctx, uri, it.reader_options)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err))
+	}
+
+	defer r.Close()
+
+	i := 0
+
+	if !it.lines {
+
+		body, err := io.ReadAll(r)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to read '%s', %w", uri, err))
+		}
+
+		return it.yieldElements(ctx, uri, uri, body, &i, yield)
+	}
+
+	reader := bufio.NewReader(r)
+
+	for ln := 1; ; ln++ {
+
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		line, err := reader.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return yield(nil, fmt.Errorf("Failed to read line %d of '%s', %w", ln, uri, err))
+		}
+
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+
+			label := fmt.Sprintf("line %d of '%s'", ln, uri)
+
+			if !it.yieldElements(ctx, uri, label, line, &i, yield) {
+				return false
+			}
+		}
+
+		if err == io.EOF {
+			return true
+		}
+	}
+}
+
+// yieldElements yields records for each element of the array at the iterator's path in the JSON document 'body'
+// which is described by 'label' in error messages. 'i' is the index of the next element in the file at 'uri' and is
+// incremented for each element. It returns false if 'yield' has signaled that iteration should stop.
+func (it *JSONPathIterator) yieldElements(ctx context.Context, uri string, label string, body []byte, i *int, yield func(rec *Record, err error) bool) bool {
+
+	if !gjson.ValidBytes(body) {
+		return yield(nil, fmt.Errorf("Failed to parse %s, invalid JSON", label))
+	}
+
+	rsp := gjson.GetBytes(body, it.path)
+
+	if !rsp.Exists() {
+		return yield(nil, fmt.Errorf("Path '%s' not found in %s", it.path, label))
+	}
+
+	if !rsp.IsArray() {
+		return yield(nil, fmt.Errorf("Path '%s' in %s is not an array", it.path, label))
+	}
+
+	ok := true
+
+	rsp.ForEach(func(_ gjson.Result, el gjson.Result) bool {
+
+		select {
+		case <-ctx.Done():
+			ok = false
+			return false
+		default:
+			// pass
+		}
+
+		path := fmt.Sprintf("%s#%d", uri, *i)
+		*i += 1
+
+		atomic.AddInt64(&it.seen, 1)
+
+		br := bytes.NewReader([]byte(el.Raw))
+		rsc, err := ioutil.NewReadSeekCloser(br)
+
+		if err != nil {
+			ok = yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err))
+			return ok
+		}
+
+		if it.filters != nil {
+
+			matches, err := ApplyFilters(ctx, rsc, it.filters)
+
+			if err != nil {
+				rsc.Close()
+				ok = yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err))
+				return ok
+			}
+
+			if !matches {
+				rsc.Close()
+				return true
+			}
+		}
+
+		rec := NewRecord(path, rsc)
+
+		ok = yield(rec, nil)
+		return ok
+	})
+
+	return ok
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *JSONPathIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *JSONPathIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *JSONPathIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,213 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// jsonPathFixtures returns the (compacted) records in the "fixtures/data" directory.
+func jsonPathFixtures(t *testing.T) [][]byte {
+
+	t.Helper()
+
+	features := make([][]byte, 0)
+
+	err := filepath.WalkDir("fixtures/data", func(path string, d os.DirEntry, err error) error {
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		buf := new(bytes.Buffer)
+
+		err = json.Compact(buf, body)
+
+		if err != nil {
+			return err
+		}
+
+		features = append(features, buf.Bytes())
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to read fixtures, %v", err)
+	}
+
+	return features
+}
+
+func TestJSONPathIterator(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	features := jsonPathFixtures(t)
+	dir := t.TempDir()
+
+	// A single document with all the features nested in an envelope
+
+	doc_path := filepath.Join(dir, "envelope.json")
+	doc := fmt.Sprintf(`{"meta":{"count":%d},"data":{"items":[%s]}}`, len(features), bytes.Join(features, []byte(",")))
+
+	err := os.WriteFile(doc_path, []byte(doc), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", doc_path, err)
+	}
+
+	// Line-separated documents with (up to) two features nested in an envelope on each line
+
+	lines := make([]string, 0)
+
+	for i := 0; i < len(features); i += 2 {
+		end := min(i+2, len(features))
+		lines = append(lines, fmt.Sprintf(`{"data":{"items":[%s]}}`, bytes.Join(features[i:end], []byte(","))))
+	}
+
+	lines_path := filepath.Join(dir, "envelope.ndjson")
+
+	err = os.WriteFile(lines_path, []byte(strings.Join(lines, "\r\n")+"\n\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", lines_path, err)
+	}
+
+	tests := map[string]map[string]int{
+		doc_path: {
+			"jsonpath://?path=data.items":                                         37,
+			"jsonpath://?path=data.items&include=properties.wof:placetype=custom": 37,
+			"jsonpath://?path=data.items&exclude=properties.wof:placetype=custom": 0,
+		},
+		lines_path: {
+			"jsonpathl://?path=data.items": 37,
+		},
+	}
+
+	for path, uris := range tests {
+
+		for iter_uri, expected := range uris {
+
+			it, err := NewIterator(ctx, iter_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new jsonpath source for '%s', %v", iter_uri, err)
+			}
+
+			count := 0
+			paths := make(map[string]bool)
+
+			for rec, err := range it.Iterate(ctx, path) {
+
+				if err != nil {
+					t.Fatalf("Failed to iterate '%s' with '%s', %v", path, iter_uri, err)
+				}
+
+				defer rec.Body.Close()
+
+				body, err := io.ReadAll(rec.Body)
+
+				if err != nil {
+					t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+				}
+
+				if !bytes.HasPrefix(body, []byte(`{"id":`)) {
+					t.Fatalf("Unexpected body for %s, %s", rec.Path, string(body))
+				}
+
+				if !strings.HasPrefix(rec.Path, path+"#") {
+					t.Fatalf("Unexpected path %s", rec.Path)
+				}
+
+				paths[rec.Path] = true
+				count += 1
+			}
+
+			if count != expected {
+				t.Fatalf("Unexpected record count for '%s'. Got %d but expected %d", iter_uri, count, expected)
+			}
+
+			if len(paths) != count {
+				t.Fatalf("Expected unique paths for '%s'", iter_uri)
+			}
+		}
+	}
+}
+
+func TestJSONPathIteratorErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir := t.TempDir()
+
+	tests := map[string]string{
+		"missing.json":   `{"data":{}}`,
+		"not-array.json": `{"data":{"items":{"type":"Feature"}}}`,
+		"invalid.json":   `{"data":`,
+	}
+
+	for fname, body := range tests {
+
+		path := filepath.Join(dir, fname)
+
+		err := os.WriteFile(path, []byte(body), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+
+		for _, iter_uri := range []string{"jsonpath://?path=data.items", "jsonpathl://?path=data.items"} {
+
+			it, err := NewJSONPathIterator(ctx, iter_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new jsonpath source for '%s', %v", iter_uri, err)
+			}
+
+			failed := false
+
+			for _, err := range it.Iterate(ctx, path) {
+
+				if err != nil {
+					failed = true
+				}
+			}
+
+			if !failed {
+				t.Fatalf("Expected iterating %s with '%s' to fail", fname, iter_uri)
+			}
+		}
+	}
+}
+
+func TestNewJSONPathIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, uri := range []string{"jsonpath://", "jsonpathl://", "jsonpath://?path="} {
+
+		_, err := NewJSONPathIterator(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", uri)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "multi", NewMultiIteratorFromURI)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MultiIterator implements the `Iterator` interface for crawling records from multiple, heterogeneous, iterators
+// concurrently as a single stream.
+type MultiIterator struct {
+	Iterator
+	// sources is a map of iterator URIs and the URIs passed to their `Iterate` method when no URIs are passed to this iterator's `Iterate` method.
+	sources map[string][]string
+	// iterators is a map of child `Iterator` instances keyed by their iterator URI.
+	iterators map[string]Iterator
+	// mu is a `sync.Mutex` instance used to guard access to 'iterators'.
+	mu *sync.Mutex
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewMultiIteratorFromURI() returns a new `MultiIterator` instance configured by 'uri' in the form of:
+//
+//	multi://
+//
+// Each URI passed to the `Iterate` method is expected to take the form of {ITERATOR_URI} + "#" + {PIPE_SEPARATED_LIST_OF_ITERATOR_SOURCES},
+// the same syntax used by the `flags.IteratorURIFlag` flag. For example "repo://#/usr/local/data/sfomuseum-data-architecture".
+func NewMultiIteratorFromURI(ctx context.Context, uri string) (Iterator, error) {
+	return NewMultiIterator(ctx, map[string][]string{})
+}
+
+// NewMultiIterator() returns a new `MultiIterator` instance for 'sources' which is a map of iterator URIs and the
+// URIs to pass to their `Iterate` method, for example the output of the `flags.MultiIteratorURIFlag.AsMap` method.
+// Each iterator URI is used to create a child iterator using the `NewIterator` method. Calling the `Iterate` method
+// without any URIs will iterate all of the children concurrently.
+func NewMultiIterator(ctx context.Context, sources map[string][]string) (Iterator, error) {
+
+	it := &MultiIterator{
+		sources:   sources,
+		iterators: make(map[string]Iterator),
+		mu:        new(sync.Mutex),
+		iterating: new(atomic.Bool),
+	}
+
+	for iter_uri := range sources {
+
+		_, err := it.childIterator(ctx, iter_uri)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered by each child iterator. If 'uris' is empty
+// the sources the iterator was created with are used, otherwise each URI is expected to take the form of {ITERATOR_URI} + "#"
+// + {PIPE_SEPARATED_LIST_OF_ITERATOR_SOURCES}. The `IteratorURI` property of each record is assigned the URI of the child
+// iterator that produced it, with any credentials scrubbed using the `ScrubURI` method.
+func (it *MultiIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		sources := it.sources
+
+		if len(uris) > 0 {
+
+			sources = make(map[string][]string)
+
+			for _, uri := range uris {
+
+				iter_uri, iter_sources, err := parseMultiIteratorURI(uri)
+
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				sources[iter_uri] = append(sources[iter_uri], iter_sources...)
+			}
+		}
+
+		iter_uris := slices.Sorted(maps.Keys(sources))
+
+		children := make(map[string]Iterator)
+
+		for _, iter_uri := range iter_uris {
+
+			child, err := it.childIterator(ctx, iter_uri)
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			children[iter_uri] = child
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		rec_ch := make(chan *Record)
+		err_ch := make(chan error)
+		done_ch := make(chan bool)
+
+		for _, iter_uri := range iter_uris {
+
+			go func(iter_uri string) {
+
+				defer func() {
+					done_ch <- true
+				}()
+
+				scrubbed_uri, err := ScrubURI(iter_uri)
+
+				if err != nil {
+					scrubbed_uri = "..."
+				}
+
+				for rec, err := range children[iter_uri].Iterate(ctx, sources[iter_uri]...) {
+
+					if err != nil {
+
+						select {
+						case <-ctx.Done():
+							return
+						case err_ch <- fmt.Errorf("Iterator '%s' failed, %w", scrubbed_uri, err):
+							continue
+						}
+					}
+
+					rec.IteratorURI = scrubbed_uri
+
+					select {
+					case <-ctx.Done():
+						rec.Body.Close()
+						return
+					case rec_ch <- rec:
+						// pass
+					}
+				}
+			}(iter_uri)
+		}
+
+		remaining := len(iter_uris)
+
+		// Cancel the children and wait for them to finish if iteration is stopped early
+
+		stop := func() {
+
+			cancel()
+
+			for remaining > 0 {
+				<-done_ch
+				remaining -= 1
+			}
+		}
+
+		for remaining > 0 {
+			select {
+			case <-done_ch:
+				remaining -= 1
+			case err := <-err_ch:
+				if !yield(nil, err) {
+					stop()
+					return
+				}
+			case rec := <-rec_ch:
+				if !yield(rec, nil) {
+					stop()
+					return
+				}
+			}
+		}
+	}
+}
+
+// childIterator returns the child `Iterator` instance for 'iter_uri', creating it if necessary.
+func (it *MultiIterator) childIterator(ctx context.Context, iter_uri string) (Iterator, error) {
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	child, ok := it.iterators[iter_uri]
+
+	if ok {
+		return child, nil
+	}
+
+	child, err := NewIterator(ctx, iter_uri)
+
+	if err != nil {
+
+		scrubbed_uri, _ := ScrubURI(iter_uri)
+		return nil, fmt.Errorf("Failed to create iterator for '%s', %w", scrubbed_uri, err)
+	}
+
+	it.iterators[iter_uri] = child
+	return child, nil
+}
+
+// Seen() returns the total number of records processed so far by all of the child iterators.
+func (it *MultiIterator) Seen() int64 {
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	seen := int64(0)
+
+	for _, child := range it.iterators {
+		seen += child.Seen()
+	}
+
+	return seen
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *MultiIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close closes each of the child iterators.
+func (it *MultiIterator) Close() error {
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	for iter_uri, child := range it.iterators {
+
+		err := child.Close()
+
+		if err != nil {
+			scrubbed_uri, _ := ScrubURI(iter_uri)
+			return fmt.Errorf("Failed to close iterator for '%s', %w", scrubbed_uri, err)
+		}
+	}
+
+	return nil
+}
+
+// parseMultiIteratorURI parses 'uri' in the form of {ITERATOR_URI} + "#" + {PIPE_SEPARATED_LIST_OF_ITERATOR_SOURCES} and
+// returns the iterator URI and its sources. The URI is split on the first "#" character.
+func parseMultiIteratorURI(uri string) (string, []string, error) {
+
+	// Only the first "#" separates the iterator URI from its sources which may themselves contain "#" characters
+
+	iter_uri, str_sources, ok := strings.Cut(uri, "#")
+
+	if !ok {
+		return "", nil, fmt.Errorf("Invalid multi iterator URI, expected {ITERATOR_URI}#{SOURCES}")
+	}
+
+	iter_sources := make([]string, 0)
+
+	for _, source := range strings.Split(str_sources, "|") {
+
+		if source != "" {
+			iter_sources = append(iter_sources, source)
+		}
+	}
+
+	if iter_uri == "" || len(iter_sources) == 0 {
+		return "", nil, fmt.Errorf("Invalid multi iterator URI, missing iterator URI or sources")
+	}
+
+	return iter_uri, iter_sources, nil
+}
@@ -0,0 +1,154 @@
+package iterate
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMultiIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	sources := map[string][]string{
+		"repo://":                       {"fixtures"},
+		"geojsonl://?access_token=s33p": {"fixtures/collection.geojsonl"},
+		"featurecollection://":          {"fixtures/collection.geojson"},
+	}
+
+	it, err := NewMultiIterator(ctx, sources)
+
+	if err != nil {
+		t.Fatalf("Failed to create new multi iterator, %v", err)
+	}
+
+	defer it.Close()
+
+	counts := make(map[string]int64)
+
+	for rec, err := range it.Iterate(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+		counts[rec.IteratorURI] += 1
+	}
+
+	// Iterator URIs are scrubbed before being assigned to records
+
+	expected := map[string]int64{
+		"repo:":                      37,
+		"geojsonl:?access_token=...": 2,
+		"featurecollection:":         2,
+	}
+
+	total := int64(0)
+
+	for iter_uri, count := range expected {
+
+		if counts[iter_uri] != count {
+			t.Fatalf("Unexpected count for %s. Got %d but expected %d", iter_uri, counts[iter_uri], count)
+		}
+
+		total += count
+	}
+
+	if it.Seen() != total {
+		t.Fatalf("Unexpected seen count. Got %d but expected %d", it.Seen(), total)
+	}
+
+	// Stopping early
+
+	count := 0
+
+	for rec, err := range it.Iterate(ctx) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+		count += 1
+
+		if count == 5 {
+			break
+		}
+	}
+
+	if count != 5 {
+		t.Fatalf("Unexpected count after stopping early, %d", count)
+	}
+}
+
+func TestMultiIteratorURI(t *testing.T) {
+
+	ctx := context.Background()
+
+	it, err := NewIterator(ctx, "multi://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new multi source, %v", err)
+	}
+
+	counts := make(map[string]int)
+
+	for rec, err := range it.Iterate(ctx, "repo://?_exclude_alt_files=true#fixtures", "geojsonl://#fixtures/collection.geojsonl|fixtures/collection.geojsonl") {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+		counts[rec.IteratorURI] += 1
+	}
+
+	if counts["repo:?_exclude_alt_files=true"] != 37 || counts["geojsonl:"] != 4 {
+		t.Fatalf("Unexpected counts, %v", counts)
+	}
+
+	// Invalid URIs
+
+	for _, uri := range []string{"repo://", "repo://#", "#fixtures", "bogus://#fixtures"} {
+
+		for _, err := range it.Iterate(ctx, uri) {
+
+			if err == nil {
+				t.Fatalf("Expected '%s' to fail", uri)
+			}
+		}
+	}
+}
+
+func TestParseMultiIteratorURI(t *testing.T) {
+
+	tests := map[string][]string{
+		"repo://#fixtures":                           {"repo://", "fixtures"},
+		"repo://?_exclude_alt_files=true#a|b":        {"repo://?_exclude_alt_files=true", "a", "b"},
+		"file://#data/#1.geojson|data/2.geojson":     {"file://", "data/#1.geojson", "data/2.geojson"},
+		"geojsonl://#fixtures/collection.geojsonl||": {"geojsonl://", "fixtures/collection.geojsonl"},
+	}
+
+	for uri, expected := range tests {
+
+		iter_uri, iter_sources, err := parseMultiIteratorURI(uri)
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", uri, err)
+		}
+
+		if iter_uri != expected[0] || !slices.Equal(iter_sources, expected[1:]) {
+			t.Fatalf("Unexpected result for '%s'. Got %s and %v", uri, iter_uri, iter_sources)
+		}
+	}
+
+	for _, uri := range []string{"repo://", "repo://#", "#fixtures", "repo://#|"} {
+
+		_, _, err := parseMultiIteratorURI(uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", uri)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// ORG_REPOS_REGEXP_PREFIX is the prefix used to signal that a `?repos=` parameter is a regular expression rather than a glob pattern.
+const ORG_REPOS_REGEXP_PREFIX string = "regexp:"
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "org", NewOrgIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// OrgIterator implements the `Iterator` interface for crawling records in every Who's On First style repository
+// in a parent directory.
+type OrgIterator struct {
+	Iterator
+	// iterator is the underlying `RepoIterator` instance for crawling the records in each repository.
+	iterator Iterator
+	// repos are the functions used to select repositories by name. If empty all repositories are selected.
+	repos []func(string) bool
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewOrgIterator() returns a new `OrgIterator` instance configured by 'uri' in the form of:
+//
+//	org://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?repos=` Zero or more glob patterns (for example "whosonfirst-data-admin-*") or, if prefixed with "regexp:", regular expressions used to select repositories by name. Repositories matching any value are selected. (Default is all repositories.)
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+//
+// Each URI passed to the `Iterate` method is a parent directory whose child directories containing a "data" directory are
+// treated as repositories. The `Repo` property of each record is assigned the name of the repository it was read from.
+func NewOrgIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	repos := make([]func(string) bool, 0)
+
+	for _, pattern := range q["repos"] {
+
+		if strings.HasPrefix(pattern, ORG_REPOS_REGEXP_PREFIX) {
+
+			re, err := regexp.Compile(strings.TrimPrefix(pattern, ORG_REPOS_REGEXP_PREFIX))
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to compile 'repos' parameter '%s', %w", pattern, err)
+			}
+
+			repos = append(repos, re.MatchString)
+			continue
+		}
+
+		_, err := path.Match(pattern, "")
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'repos' parameter '%s', %w", pattern, err)
+		}
+
+		repos = append(repos, func(name string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		})
+	}
+
+	repo_it, err := NewRepoIterator(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new repo iterator, %w", err)
+	}
+
+	it := &OrgIterator{
+		iterator:  repo_it,
+		repos:     repos,
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in the repositories in 'uris'.
+func (it *OrgIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			repo_paths, err := it.findRepos(uri)
+
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+
+				continue
+			}
+
+			if len(repo_paths) == 0 {
+				slog.Warn("No repositories found", "uri", uri)
+				continue
+			}
+
+			slog.Debug("Found repositories", "uri", uri, "count", len(repo_paths))
+
+			for rec, err := range it.iterator.Iterate(ctx, repo_paths...) {
+
+				if !yield(rec, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// findRepos returns the absolute paths of the child directories of 'uri' that contain a "data" directory and whose
+// names are selected by the iterator's `?repos=` parameters.
+func (it *OrgIterator) findRepos(uri string) ([]string, error) {
+
+	abs_path, err := filepath.Abs(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err)
+	}
+
+	entries, err := os.ReadDir(abs_path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory '%s', %w", abs_path, err)
+	}
+
+	repo_paths := make([]string, 0)
+
+	for _, e := range entries {
+
+		name := e.Name()
+
+		if !it.isSelected(name) {
+			continue
+		}
+
+		repo_path := filepath.Join(abs_path, name)
+
+		// Use os.Stat rather than the directory entry so that symlinked repositories are followed
+		info, err := os.Stat(filepath.Join(repo_path, "data"))
+
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		repo_paths = append(repo_paths, repo_path)
+	}
+
+	return repo_paths, nil
+}
+
+// isSelected returns a boolean value indicating whether the repository 'name' is selected by the iterator's `?repos=` parameters.
+func (it *OrgIterator) isSelected(name string) bool {
+
+	if len(it.repos) == 0 {
+		return true
+	}
+
+	for _, match := range it.repos {
+
+		if match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *OrgIterator) Seen() int64 {
+	return it.iterator.Seen()
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *OrgIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *OrgIterator) Close() error {
+	return it.iterator.Close()
+}
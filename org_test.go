@@ -0,0 +1,146 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOrgIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	fixtures := []string{
+		"174/657/420/7/1746574207.geojson",
+		"174/612/434/7/1746124347.geojson",
+		"147/788/174/3/1477881743.geojson",
+	}
+
+	// Repository names and the number of records in each
+
+	repos := map[string]int{
+		"whosonfirst-data-admin-us": 3,
+		"whosonfirst-data-admin-ca": 2,
+		"whosonfirst-data-venue-us": 1,
+	}
+
+	org_root := t.TempDir()
+
+	for name, count := range repos {
+
+		for _, rel_path := range fixtures[:count] {
+
+			body, err := os.ReadFile(filepath.Join("fixtures/data", rel_path))
+
+			if err != nil {
+				t.Fatalf("Failed to read fixture, %v", err)
+			}
+
+			path := filepath.Join(org_root, name, "data", rel_path)
+
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+
+			if err != nil {
+				t.Fatalf("Failed to create directory for %s, %v", path, err)
+			}
+
+			err = os.WriteFile(path, body, 0644)
+
+			if err != nil {
+				t.Fatalf("Failed to write %s, %v", path, err)
+			}
+		}
+	}
+
+	// Things which are not repositories
+
+	err := os.MkdirAll(filepath.Join(org_root, "whosonfirst-data-admin-xx", "docs"), 0755)
+
+	if err != nil {
+		t.Fatalf("Failed to create directory, %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(org_root, "README.md"), []byte("# Hello world"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write README, %v", err)
+	}
+
+	// Repos parameters and the expected repositories
+
+	tests := map[string][]string{
+		"": {
+			"whosonfirst-data-admin-ca",
+			"whosonfirst-data-admin-us",
+			"whosonfirst-data-venue-us",
+		},
+		"whosonfirst-data-admin-*": {
+			"whosonfirst-data-admin-ca",
+			"whosonfirst-data-admin-us",
+		},
+		"regexp:-(ca|venue-us)$": {
+			"whosonfirst-data-admin-ca",
+			"whosonfirst-data-venue-us",
+		},
+		"whosonfirst-data-*-nz": {},
+	}
+
+	for repos_param, expected := range tests {
+
+		q := url.Values{}
+
+		if repos_param != "" {
+			q.Set("repos", repos_param)
+		}
+
+		it, err := NewIterator(ctx, fmt.Sprintf("org://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create new org source for '%s', %v", repos_param, err)
+		}
+
+		counts := make(map[string]int)
+
+		for rec, err := range it.Iterate(ctx, org_root) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' for '%s', %v", org_root, repos_param, err)
+			}
+
+			rec.Body.Close()
+			counts[rec.Repo] += 1
+		}
+
+		found := slices.Sorted(maps.Keys(counts))
+
+		if !slices.Equal(found, expected) {
+			t.Fatalf("Unexpected repositories for '%s'. Got %v but expected %v", repos_param, found, expected)
+		}
+
+		for name, count := range counts {
+
+			if count != repos[name] {
+				t.Fatalf("Unexpected record count for %s. Got %d but expected %d", name, count, repos[name])
+			}
+		}
+	}
+}
+
+func TestNewOrgIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, uri := range []string{"org://?repos=whosonfirst-data-[", "org://?repos=regexp:whosonfirst-data-("} {
+
+		_, err := NewOrgIterator(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", uri)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+// OVERLAY_BASE is the default iterator URI used to crawl the base records for `OverlayIterator`.
+const OVERLAY_BASE string = "repo://"
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "overlay", NewOverlayIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// overlaySource is an `Iterator` instance and the path it crawls for overlay records.
+type overlaySource struct {
+	// iterator is the `Iterator` instance used to crawl overlay records.
+	iterator Iterator
+	// path is the URI passed to the iterator's `Iterate` method.
+	path string
+}
+
+// overlayRecord is an indexed overlay record.
+type overlayRecord struct {
+	// path is the path of the overlay record.
+	path string
+	// body is the body of the overlay record.
+	body []byte
+	// info is the (optional) `fs.FileInfo` instance for the overlay record.
+	info fs.FileInfo
+	// deleted is a boolean value indicating whether the overlay record is a tombstone.
+	deleted bool
+}
+
+// OverlayIterator implements the `Iterator` interface for crawling the combined view of a base source of Who's On
+// First records and one or more overlay sources whose records replace (or delete) base records with the same ID.
+type OverlayIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific (merged) records from being crawled.
+	filters filters.Filters
+	// base is the `Iterator` instance used to crawl base records.
+	base Iterator
+	// overlays are the sources of overlay records, in the order they are applied.
+	overlays []*overlaySource
+	// include_tombstones is a boolean value indicating whether deleted records should be yielded.
+	include_tombstones bool
+	// index_once is a `sync.Once` instance used to ensure that the overlay records are only indexed once.
+	index_once *sync.Once
+	// index is the map of overlay records keyed by their relative Who's On First path.
+	index map[string]*overlayRecord
+	// index_keys are the keys in 'index' in the order they were first encountered.
+	index_keys []string
+	// index_err is the error, if any, encountered indexing the overlay records.
+	index_err error
+	// yielded is the set of keys that have already been yielded, across all calls to the `Iterate` method.
+	yielded map[string]bool
+	// iterated is the set of base URIs that have already been passed to the `Iterate` method.
+	iterated map[string]bool
+	// yielded_mu is a `sync.Mutex` instance used to guard access to 'yielded' and 'iterated'.
+	yielded_mu *sync.Mutex
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewOverlayIterator() returns a new `OverlayIterator` instance configured by 'uri' in the form of:
+//
+//	overlay://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?base=` The iterator URI used to crawl base records in each of the URIs passed to the `Iterate` method. (Default is "repo://".)
+// * `?overlay=` Zero or more iterator URIs, in the form of "{SCHEME}://{PATH}?{PARAMETERS}", used to crawl overlay records in {PATH}. Overlays are applied in order so records in later overlays replace those in earlier ones.
+// * `?include_tombstones=` A boolean value indicating whether deleted records should be yielded (with their `Deleted` property set to true) rather than omitted. (Default is false.)
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a (merged) document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a (merged) document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+//
+// Records are matched by their Who's On First ID and, for alternate geometry files, their alt label as parsed from their paths
+// using `whosonfirst/go-whosonfirst-uri`. Overlay records which are flagged as deleted, or whose body is empty, are tombstones.
+// The `?include=` and `?exclude=` filters are applied to every yielded record except tombstones, which are always yielded
+// (when `?include_tombstones=` is true) so that consumers never miss a deletion.
+//
+// `OverlayIterator` instances are single-use: each ID is yielded once across all of the base URIs passed to the `Iterate`
+// method, by one or more calls, and passing a base URI which has already been iterated yields an error. For the same reason
+// the `?_retry=` parameter, which iterates a base URI again if it fails, is not supported.
+func NewOverlayIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	base_uri := OVERLAY_BASE
+
+	if q.Has("base") {
+		base_uri = q.Get("base")
+	}
+
+	base, err := newUnwrappedIterator(ctx, base_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create base iterator, %w", err)
+	}
+
+	overlays := make([]*overlaySource, 0)
+
+	for _, overlay_uri := range q["overlay"] {
+
+		scheme, rest, ok := strings.Cut(overlay_uri, "://")
+
+		if !ok {
+			return nil, fmt.Errorf("Invalid 'overlay' parameter '%s', missing scheme", overlay_uri)
+		}
+
+		path, query, _ := strings.Cut(rest, "?")
+
+		if path == "" {
+			return nil, fmt.Errorf("Invalid 'overlay' parameter '%s', missing path", overlay_uri)
+		}
+
+		overlay_it, err := newUnwrappedIterator(ctx, fmt.Sprintf("%s://?%s", scheme, query))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create overlay iterator for '%s', %w", overlay_uri, err)
+		}
+
+		overlays = append(overlays, &overlaySource{
+			iterator: overlay_it,
+			path:     path,
+		})
+	}
+
+	if q.Has("_retry") {
+
+		retry, err := strconv.ParseBool(q.Get("_retry"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '_retry' parameter, %w", err)
+		}
+
+		if retry {
+			return nil, fmt.Errorf("The '_retry' parameter is not supported")
+		}
+	}
+
+	include_tombstones := false
+
+	if q.Has("include_tombstones") {
+
+		v, err := strconv.ParseBool(q.Get("include_tombstones"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'include_tombstones' parameter, %w", err)
+		}
+
+		include_tombstones = v
+	}
+
+	it := &OverlayIterator{
+		filters:            f,
+		base:               base,
+		overlays:           overlays,
+		include_tombstones: include_tombstones,
+		index_once:         new(sync.Once),
+		yielded:            make(map[string]bool),
+		iterated:           make(map[string]bool),
+		yielded_mu:         new(sync.Mutex),
+		seen:               int64(0),
+		iterating:          new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record in the combined view of the base records
+// encountered in 'uris' and the overlay records. Each ID (and alt label) is yielded once: base records are replaced,
+// in place, by the matching overlay record and overlay records without a matching base record are yielded last.
+// The overlay records are indexed once per iterator and each ID is yielded once across all calls to this method, so
+// that base URIs iterated separately (for example by the `NewIterator` wrapper) are merged in to a single view. An error
+// is yielded, and nothing else, if any of 'uris' has already been iterated.
+func (it *OverlayIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		err := it.claimURIs(uris)
+
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		it.index_once.Do(func() {
+			it.index, it.index_keys, it.index_err = it.indexOverlays(ctx)
+		})
+
+		if it.index_err != nil {
+			yield(nil, it.index_err)
+			return
+		}
+
+		index := it.index
+		keys := it.index_keys
+
+		for rec, err := range it.base.Iterate(ctx, uris...) {
+
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+
+				continue
+			}
+
+			key, err := overlayKey(rec.Path)
+
+			// Records which are not Who's On First documents can not be overlaid
+			if err != nil {
+
+				if !it.yieldRecord(ctx, rec, yield) {
+					return
+				}
+
+				continue
+			}
+
+			if !it.claimKey(key) {
+				rec.Body.Close()
+				continue
+			}
+
+			o, ok := index[key]
+
+			if !ok {
+
+				if !it.yieldRecord(ctx, rec, yield) {
+					return
+				}
+
+				continue
+			}
+
+			if o.deleted {
+
+				if !it.include_tombstones {
+					atomic.AddInt64(&it.seen, 1)
+					rec.Body.Close()
+					continue
+				}
+
+				// The base record is the last known version of the deleted record
+				atomic.AddInt64(&it.seen, 1)
+				rec.Deleted = true
+
+				if !yield(rec, nil) {
+					return
+				}
+
+				continue
+			}
+
+			rec.Body.Close()
+
+			if !it.yieldOverlayRecord(ctx, o, yield) {
+				return
+			}
+		}
+
+		for _, key := range keys {
+
+			if !it.claimKey(key) {
+				continue
+			}
+
+			o := index[key]
+
+			if o.deleted && !it.include_tombstones {
+				continue
+			}
+
+			if !it.yieldOverlayRecord(ctx, o, yield) {
+				return
+			}
+		}
+	}
+}
+
+// claimURIs records that 'uris' are being iterated. It returns an error if any of them have already been iterated.
+func (it *OverlayIterator) claimURIs(uris []string) error {
+
+	it.yielded_mu.Lock()
+	defer it.yielded_mu.Unlock()
+
+	for _, uri := range uris {
+
+		if it.iterated[uri] {
+			return fmt.Errorf("Base URI '%s' has already been iterated, overlay iterators can not be reused", uri)
+		}
+	}
+
+	for _, uri := range uris {
+		it.iterated[uri] = true
+	}
+
+	return nil
+}
+
+// claimKey records that 'key' is being yielded. It returns false if 'key' has already been yielded.
+func (it *OverlayIterator) claimKey(key string) bool {
+
+	it.yielded_mu.Lock()
+	defer it.yielded_mu.Unlock()
+
+	if it.yielded[key] {
+		return false
+	}
+
+	it.yielded[key] = true
+	return true
+}
+
+// indexOverlays crawls each overlay source, in order, and returns a map of overlay records keyed by their
+// relative Who's On First path along with the keys in the order they were first encountered.
+func (it *OverlayIterator) indexOverlays(ctx context.Context) (map[string]*overlayRecord, []string, error) {
+
+	index := make(map[string]*overlayRecord)
+	keys := make([]string, 0)
+
+	for _, src := range it.overlays {
+
+		for rec, err := range src.iterator.Iterate(ctx, src.path) {
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to iterate overlay '%s', %w", src.path, err)
+			}
+
+			key, err := overlayKey(rec.Path)
+
+			if err != nil {
+				rec.Body.Close()
+				slog.Warn("Skipping overlay record which is not a Who's On First document", "path", rec.Path, "error", err)
+				continue
+			}
+
+			body, err := io.ReadAll(rec.Body)
+			rec.Body.Close()
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to read overlay record '%s', %w", rec.Path, err)
+			}
+
+			_, exists := index[key]
+
+			if !exists {
+				keys = append(keys, key)
+			}
+
+			index[key] = &overlayRecord{
+				path:    rec.Path,
+				body:    body,
+				info:    rec.Info,
+				deleted: rec.Deleted || len(bytes.TrimSpace(body)) == 0,
+			}
+		}
+	}
+
+	slog.Debug("Indexed overlay records", "count", len(keys))
+	return index, keys, nil
+}
+
+// yieldOverlayRecord yields a new record for the overlay record 'o'. Tombstones are yielded with an empty body.
+// It returns false if iteration should stop.
+func (it *OverlayIterator) yieldOverlayRecord(ctx context.Context, o *overlayRecord, yield func(rec *Record, err error) bool) bool {
+
+	body := o.body
+
+	if o.deleted {
+		body = nil
+	}
+
+	r, err := ioutil.NewReadSeekCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create ReadSeekCloser for '%s', %w", o.path, err))
+	}
+
+	rec := NewRecord(o.path, r)
+	rec.Info = o.info
+
+	if o.deleted {
+		atomic.AddInt64(&it.seen, 1)
+		rec.Deleted = true
+		return yield(rec, nil)
+	}
+
+	return it.yieldRecord(ctx, rec, yield)
+}
+
+// yieldRecord applies the iterator's filters to 'rec' and yields it if they match. It returns false if iteration should stop.
+func (it *OverlayIterator) yieldRecord(ctx context.Context, rec *Record, yield func(rec *Record, err error) bool) bool {
+
+	atomic.AddInt64(&it.seen, 1)
+
+	if it.filters != nil {
+
+		ok, err := ApplyFilters(ctx, rec.Body, it.filters)
+
+		if err != nil {
+			rec.Body.Close()
+			return yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", rec.Path, err))
+		}
+
+		if !ok {
+			rec.Body.Close()
+			return true
+		}
+	}
+
+	return yield(rec, nil)
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *OverlayIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *OverlayIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *OverlayIterator) Close() error {
+
+	err := it.base.Close()
+
+	if err != nil {
+		return err
+	}
+
+	for _, src := range it.overlays {
+
+		err := src.iterator.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// overlayKey returns the relative Who's On First path, derived from the ID and alt label parsed from 'path',
+// used to match base and overlay records.
+func overlayKey(path string) (string, error) {
+
+	id, uri_args, err := uri.ParseURI(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return uri.Id2RelPath(id, uri_args)
+}
@@ -0,0 +1,249 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlayIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	read := func(rel_path string) []byte {
+
+		body, err := os.ReadFile(filepath.Join("fixtures/data", rel_path))
+
+		if err != nil {
+			t.Fatalf("Failed to read fixture, %v", err)
+		}
+
+		return body
+	}
+
+	write := func(root string, fname string, body []byte) {
+
+		err := os.WriteFile(filepath.Join(root, fname), body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", fname, err)
+		}
+	}
+
+	modified := read("174/657/420/7/1746574207.geojson")
+	other := read("147/788/174/3/1477881743.geojson")
+
+	// The first overlay modifies one record, deletes another and adds a new record and an alternate geometry
+
+	overlay_1 := t.TempDir()
+
+	write(overlay_1, "1746574207.geojson", append(modified, '\n'))
+	write(overlay_1, "1746124347.geojson", []byte(""))
+	write(overlay_1, "9999999.geojson", other)
+	write(overlay_1, "1477881743-alt-quattroshapes.geojson", other)
+	write(overlay_1, "README.md", []byte("# Pending edits"))
+
+	// The second overlay modifies the same record again
+
+	overlay_2 := t.TempDir()
+
+	write(overlay_2, "1746574207.geojson", append(modified, '\n', '\n'))
+
+	for _, include_tombstones := range []bool{false, true} {
+
+		q := url.Values{}
+		q.Set("base", "repo://")
+		q.Add("overlay", fmt.Sprintf("directory://%s", overlay_1))
+		q.Add("overlay", fmt.Sprintf("directory://%s", overlay_2))
+		q.Set("include_tombstones", fmt.Sprintf("%t", include_tombstones))
+
+		it, err := NewIterator(ctx, fmt.Sprintf("overlay://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create new overlay source, %v", err)
+		}
+
+		keys := make(map[string]int)
+		deleted := 0
+
+		for rec, err := range it.Iterate(ctx, "fixtures") {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate, %v", err)
+			}
+
+			body, err := io.ReadAll(rec.Body)
+			rec.Body.Close()
+
+			if err != nil {
+				t.Fatalf("Failed to read %s, %v", rec.Path, err)
+			}
+
+			key, err := overlayKey(rec.Path)
+
+			if err != nil {
+				t.Fatalf("Unexpected record, %s", rec.Path)
+			}
+
+			keys[key] += 1
+
+			if rec.Deleted {
+
+				deleted += 1
+
+				if key != "174/612/434/7/1746124347.geojson" || len(body) == 0 {
+					t.Fatalf("Unexpected deleted record, %s", rec.Path)
+				}
+
+				continue
+			}
+
+			switch key {
+			case "174/657/420/7/1746574207.geojson":
+
+				if !bytes.Equal(body, append(modified, '\n', '\n')) {
+					t.Fatalf("Unexpected body for %s", rec.Path)
+				}
+
+			case "174/612/434/7/1746124347.geojson":
+				t.Fatalf("Deleted record was yielded, %s", rec.Path)
+			}
+		}
+
+		expected_count := 38
+		expected_deleted := 0
+
+		if include_tombstones {
+			expected_count = 39
+			expected_deleted = 1
+		}
+
+		if len(keys) != expected_count {
+			t.Fatalf("Unexpected record count. Got %d but expected %d", len(keys), expected_count)
+		}
+
+		if deleted != expected_deleted {
+			t.Fatalf("Unexpected deleted count. Got %d but expected %d", deleted, expected_deleted)
+		}
+
+		for key, count := range keys {
+
+			if count != 1 {
+				t.Fatalf("Record %s yielded %d times", key, count)
+			}
+		}
+
+		for _, key := range []string{"999/999/9/9999999.geojson", "147/788/174/3/1477881743-alt-quattroshapes.geojson", "147/788/174/3/1477881743.geojson"} {
+
+			if keys[key] != 1 {
+				t.Fatalf("Missing record %s", key)
+			}
+		}
+	}
+}
+
+func TestNewOverlayIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, uri := range []string{"overlay://?base=bogus://", "overlay://?overlay=directory://", "overlay://?overlay=fixtures", "overlay://?include_tombstones=maybe", "overlay://?_retry=true"} {
+
+		_, err := NewOverlayIterator(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", uri)
+		}
+	}
+}
+
+func TestOverlayIteratorMultipleURIs(t *testing.T) {
+
+	ctx := context.Background()
+
+	copy := func(root string, rel_path string) {
+
+		body, err := os.ReadFile(filepath.Join("fixtures/data", rel_path))
+
+		if err != nil {
+			t.Fatalf("Failed to read fixture, %v", err)
+		}
+
+		err = os.WriteFile(filepath.Join(root, filepath.Base(rel_path)), body, 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", rel_path, err)
+		}
+	}
+
+	base_a := t.TempDir()
+	base_b := t.TempDir()
+	overlay := t.TempDir()
+
+	copy(base_a, "174/657/420/7/1746574207.geojson")
+	copy(base_b, "174/612/434/7/1746124347.geojson")
+	copy(overlay, "174/657/420/7/1746574207.geojson")
+	copy(overlay, "147/788/174/3/1477881743.geojson")
+
+	q := url.Values{}
+	q.Set("base", "directory://")
+	q.Add("overlay", fmt.Sprintf("directory://%s", overlay))
+
+	it, err := NewIterator(ctx, fmt.Sprintf("overlay://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create new overlay source, %v", err)
+	}
+
+	keys := make(map[string]int)
+
+	for rec, err := range it.Iterate(ctx, base_a, base_b) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate, %v", err)
+		}
+
+		rec.Body.Close()
+
+		key, err := overlayKey(rec.Path)
+
+		if err != nil {
+			t.Fatalf("Unexpected record, %s", rec.Path)
+		}
+
+		keys[key] += 1
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("Unexpected record count. Got %d but expected 3", len(keys))
+	}
+
+	for key, count := range keys {
+
+		if count != 1 {
+			t.Fatalf("Record %s yielded %d times", key, count)
+		}
+	}
+
+	// Instances are single-use
+
+	failed := false
+
+	for rec, err := range it.Iterate(ctx, base_a) {
+
+		if err == nil {
+			rec.Body.Close()
+			t.Fatalf("Expected iterating %s again to fail", base_a)
+		}
+
+		failed = true
+	}
+
+	if !failed {
+		t.Fatalf("Expected iterating %s again to fail", base_a)
+	}
+}
@@ -4,24 +4,139 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 )
 
 // STDIN is a constant value signaling that a record was read from `STDIN` and has no URI (path).
 const STDIN string = "STDIN"
 
-// ReaderWithPath returns a new `io.ReadSeekCloser` instance derived from 'abs_path'.
+// ReaderOptions defines configuration options for reading files.
+type ReaderOptions struct {
+	// Compression is the compression scheme used to read files. It is expected to be one of the `COMPRESSION_` constants.
+	Compression string
+	// HTTP is an optional `HTTPOptions` instance used to configure how remote (http:// or https://) files are read.
+	HTTP *HTTPOptions
+}
+
+// NewReaderOptionsFromQuery returns a new `ReaderOptions` instance derived from 'q' which is expected to contain
+// zero or more of the following parameters:
+// * `?compression=` The compression scheme used to read files. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * Any of the parameters supported by `NewHTTPOptionsFromQuery`.
+func NewReaderOptionsFromQuery(ctx context.Context, q url.Values) (*ReaderOptions, error) {
+
+	compression, err := compressionFromQuery(q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive compression from query, %w", err)
+	}
+
+	http_opts, err := NewHTTPOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive HTTP options from query, %w", err)
+	}
+
+	opts := &ReaderOptions{
+		Compression: compression,
+		HTTP:        http_opts,
+	}
+
+	return opts, nil
+}
+
+// ReaderWithPath returns a new `io.ReadSeekCloser` instance derived from 'abs_path'. Files compressed
+// using gzip, bzip2 or zstd are decompressed automatically.
 func ReaderWithPath(ctx context.Context, abs_path string) (io.ReadSeekCloser, error) {
 
-	if abs_path == STDIN {
-		return os.Stdin, nil
+	opts := &ReaderOptions{
+		Compression: COMPRESSION_AUTO,
+	}
+
+	return ReaderWithPathAndOptions(ctx, abs_path, opts)
+}
+
+// ReaderWithPathAndCompression returns a new `io.ReadSeekCloser` instance derived from 'abs_path' decompressing
+// its contents using 'compression' which is expected to be one of the `COMPRESSION_` constants.
+func ReaderWithPathAndCompression(ctx context.Context, abs_path string, compression string) (io.ReadSeekCloser, error) {
+
+	opts := &ReaderOptions{
+		Compression: compression,
+	}
+
+	return ReaderWithPathAndOptions(ctx, abs_path, opts)
+}
+
+// ReaderWithPathAndOptions returns a new `io.ReadSeekCloser` instance derived from 'abs_path' and configured by 'opts'.
+// If 'abs_path' is a remote (http:// or https://) URI its contents will be streamed over HTTP.
+//
+// If the compression scheme in 'opts' is `COMPRESSION_AUTO` it will be derived from the leading "magic" bytes of
+// 'abs_path' or, failing that, its file extension. The compression scheme of remote files is derived from their
+// file extension only. Since `STDIN` can not be rewound after being inspected its compression scheme must be
+// specified explicitly.
+func ReaderWithPathAndOptions(ctx context.Context, abs_path string, opts *ReaderOptions) (io.ReadSeekCloser, error) {
+
+	var r io.ReadSeekCloser
+	compression := opts.Compression
+
+	switch {
+	case abs_path == STDIN:
+
+		r = os.Stdin
+
+		if compression == COMPRESSION_AUTO {
+			compression = COMPRESSION_NONE
+		}
+
+	case isRemotePath(abs_path):
+
+		http_r, err := newHTTPReader(ctx, abs_path, opts.HTTP)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create HTTP reader, %w", err)
+		}
+
+		r = http_r
+
+		if compression == COMPRESSION_AUTO {
+
+			u, _ := url.Parse(abs_path)
+			compression = compressionFromExtension(u.Path)
+		}
+
+	default:
+
+		fh, err := os.Open(abs_path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", abs_path, err)
+		}
+
+		r = fh
+	}
+
+	if compression == COMPRESSION_AUTO {
+
+		c, err := detectCompression(r, abs_path)
+
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("Failed to detect compression for %s, %w", abs_path, err)
+		}
+
+		compression = c
+	}
+
+	if compression == COMPRESSION_NONE {
+		return r, nil
 	}
 
-	r, err := os.Open(abs_path)
+	dr, err := newDecompressReader(r, compression)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to open %s, %w", abs_path, err)
+		r.Close()
+		return nil, fmt.Errorf("Failed to create decompression reader for %s, %w", abs_path, err)
 	}
 
-	return r, nil
+	return dr, nil
 }
@@ -2,6 +2,7 @@ package iterate
 
 import (
 	"io"
+	"io/fs"
 )
 
 // Record is a struct wrapping the details of records processed by a `whosonfirst/go-whosonfirst-iterate/v3.Iterator` instance.
@@ -11,6 +12,27 @@ type Record struct {
 	Path string
 	// Body is an `io.ReadSeekCloser` containing the body of the record.
 	Body io.ReadSeekCloser
+	// Info is an optional `fs.FileInfo` instance containing details (modification time, size, etc.) about the record.
+	// Not all `whosonfirst/go-whosonfirst-iterate/v3.Iterator` implementations assign this property.
+	Info fs.FileInfo
+	// Deleted is a boolean flag signaling that the record has been deleted (a "tombstone"). When true the Body
+	// property contains the last known version of the record, if it is available, or is empty. Not all `whosonfirst/go-whosonfirst-iterate/v3.Iterator`
+	// implementations assign this property.
+	Deleted bool
+	// Offset is the byte offset of the record in the (uncompressed) file it was read from. Not all
+	// `whosonfirst/go-whosonfirst-iterate/v3.Iterator` implementations assign this property.
+	Offset int64
+	// Length is the number of bytes the record occupies in the (uncompressed) file it was read from, including any
+	// trailing delimiters, such that Offset + Length is the offset of the next record. Not all
+	// `whosonfirst/go-whosonfirst-iterate/v3.Iterator` implementations assign this property in which case it is zero.
+	Length int64
+	// Repo is the name of the (Who's On First) repository the record was read from. Not all
+	// `whosonfirst/go-whosonfirst-iterate/v3.Iterator` implementations assign this property.
+	Repo string
+	// IteratorURI is the (scrubbed) URI of the iterator that produced the record when it was produced by a composite
+	// iterator, like `MultiIterator`, with multiple child iterators. Not all `whosonfirst/go-whosonfirst-iterate/v3.Iterator`
+	// implementations assign this property.
+	IteratorURI string
 }
 
 // NewRecord returns a new `Record` instance wrapping 'path' and 'r'.
@@ -47,27 +47,46 @@ func NewRepoIterator(ctx context.Context, uri string) (Iterator, error) {
 	return it, nil
 }
 
-// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'. The `Repo` property of
+// each record is assigned the name of the repository (directory) it was read from.
 func (it *RepoIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
 
-	data_uris := make([]string, len(uris))
+	return func(yield func(rec *Record, err error) bool) {
 
-	for idx, path := range uris {
+		for _, path := range uris {
 
-		abs_path, err := filepath.Abs(path)
+			abs_path, err := filepath.Abs(path)
 
-		if err != nil {
+			if err != nil {
 
-			return func(yield func(rec *Record, err error) bool) {
-				yield(nil, err)
+				if !yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", path, err)) {
+					return
+				}
+
+				continue
 			}
-		}
 
-		data_path := filepath.Join(abs_path, "data")
-		data_uris[idx] = data_path
-	}
+			data_path := filepath.Join(abs_path, "data")
+			repo := filepath.Base(abs_path)
+
+			for rec, err := range it.iterator.Iterate(ctx, data_path) {
+
+				if err != nil {
+					if !yield(nil, err) {
+						return
+					}
 
-	return it.iterator.Iterate(ctx, data_uris...)
+					continue
+				}
+
+				rec.Repo = repo
+
+				if !yield(rec, nil) {
+					return
+				}
+			}
+		}
+	}
 }
 
 // Seen() returns the total number of records processed so far.
@@ -36,6 +36,10 @@ func TestRepoIterator(t *testing.T) {
 			break
 		}
 
+		if rec.Repo != "fixtures" {
+			t.Fatalf("Unexpected repo for %s, %s", rec.Path, rec.Repo)
+		}
+
 		defer rec.Body.Close()
 		_, err = io.ReadAll(rec.Body)
 
@@ -0,0 +1,224 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// DBF_HEADER_SIZE is the size, in bytes, of the fixed portion of the header of a ".dbf" file.
+const DBF_HEADER_SIZE int = 32
+
+// DBF_FIELD_SIZE is the size, in bytes, of a field descriptor in the header of a ".dbf" file.
+const DBF_FIELD_SIZE int = 32
+
+// dbfField is a field (column) defined in a ".dbf" file.
+type dbfField struct {
+	name     string
+	typ      byte
+	length   int
+	decimals int
+}
+
+// dbfReader reads the records in a ".dbf" file sequentially.
+type dbfReader struct {
+	reader        io.Reader
+	decoder       *encoding.Decoder
+	fields        []*dbfField
+	num_records   int64
+	record_length int
+	read          int64
+}
+
+// newDbfReader reads the header of the ".dbf" file in 'r' and returns a new `dbfReader` instance for reading its records
+// whose character values are decoded using 'enc'.
+func newDbfReader(r io.Reader, enc encoding.Encoding) (*dbfReader, error) {
+
+	header := make([]byte, DBF_HEADER_SIZE)
+
+	_, err := io.ReadFull(r, header)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read header, %w", err)
+	}
+
+	num_records := int64(binary.LittleEndian.Uint32(header[4:8]))
+	header_length := int(binary.LittleEndian.Uint16(header[8:10]))
+	record_length := int(binary.LittleEndian.Uint16(header[10:12]))
+
+	if header_length < DBF_HEADER_SIZE+1 || record_length < 1 {
+		return nil, fmt.Errorf("Invalid header (header length %d, record length %d)", header_length, record_length)
+	}
+
+	descriptors := make([]byte, header_length-DBF_HEADER_SIZE)
+
+	_, err = io.ReadFull(r, descriptors)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read field descriptors, %w", err)
+	}
+
+	d := &dbfReader{
+		reader:        r,
+		decoder:       enc.NewDecoder(),
+		fields:        make([]*dbfField, 0),
+		num_records:   num_records,
+		record_length: record_length,
+	}
+
+	// The first byte of each record is the deletion flag
+	size := 1
+
+	for offset := 0; offset+DBF_FIELD_SIZE <= len(descriptors) && descriptors[offset] != 0x0D; offset += DBF_FIELD_SIZE {
+
+		desc := descriptors[offset : offset+DBF_FIELD_SIZE]
+
+		name, _, _ := bytes.Cut(desc[0:11], []byte{0x00})
+
+		name, err := d.decoder.Bytes(name)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode field name, %w", err)
+		}
+
+		f := &dbfField{
+			name:     strings.TrimSpace(string(name)),
+			typ:      desc[11],
+			length:   int(desc[16]),
+			decimals: int(desc[17]),
+		}
+
+		d.fields = append(d.fields, f)
+		size += f.length
+	}
+
+	if size > record_length {
+		return nil, fmt.Errorf("Invalid header, fields (%d bytes) exceed record length (%d bytes)", size, record_length)
+	}
+
+	return d, nil
+}
+
+// Next returns the properties for the next record in the file and a boolean flag indicating whether the record
+// has been marked as deleted. It returns `io.EOF` if there are no more records to read.
+func (d *dbfReader) Next() (map[string]any, bool, error) {
+
+	if d.read >= d.num_records {
+		return nil, false, io.EOF
+	}
+
+	buf := make([]byte, d.record_length)
+
+	_, err := io.ReadFull(d.reader, buf)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to read record %d, %w", d.read, err)
+	}
+
+	d.read += 1
+
+	deleted := buf[0] == '*'
+	props := make(map[string]any)
+
+	offset := 1
+
+	for _, f := range d.fields {
+
+		v, err := d.decodeValue(f, buf[offset:offset+f.length])
+
+		if err != nil {
+			return nil, false, fmt.Errorf("Failed to decode value for field '%s', %w", f.name, err)
+		}
+
+		props[f.name] = v
+		offset += f.length
+	}
+
+	return props, deleted, nil
+}
+
+// decodeValue returns the value for field 'f' encoded in 'raw'. Empty numeric, logical and date values are returned as nil.
+func (d *dbfReader) decodeValue(f *dbfField, raw []byte) (any, error) {
+
+	switch f.typ {
+	case 'N', 'F':
+
+		str := strings.TrimSpace(string(raw))
+
+		if str == "" || strings.Trim(str, "*") == "" {
+			return nil, nil
+		}
+
+		if f.decimals == 0 {
+
+			i, err := strconv.ParseInt(str, 10, 64)
+
+			if err == nil {
+				return i, nil
+			}
+		}
+
+		v, err := strconv.ParseFloat(str, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number '%s', %w", str, err)
+		}
+
+		return v, nil
+
+	case 'L':
+
+		switch strings.TrimSpace(string(raw)) {
+		case "T", "t", "Y", "y":
+			return true, nil
+		case "F", "f", "N", "n":
+			return false, nil
+		default:
+			return nil, nil
+		}
+
+	case 'D':
+
+		str := strings.TrimSpace(string(raw))
+
+		if len(str) != 8 {
+			return nil, nil
+		}
+
+		return fmt.Sprintf("%s-%s-%s", str[0:4], str[4:6], str[6:8]), nil
+
+	case 'I':
+
+		if len(raw) != 4 {
+			return nil, fmt.Errorf("Invalid integer length %d", len(raw))
+		}
+
+		return int64(int32(binary.LittleEndian.Uint32(raw))), nil
+
+	case 'O':
+
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("Invalid double length %d", len(raw))
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(raw)), nil
+
+	default:
+
+		raw = bytes.TrimRight(raw, " \x00")
+
+		v, err := d.decoder.Bytes(raw)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return string(v), nil
+	}
+}
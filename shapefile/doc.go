@@ -0,0 +1,27 @@
+// Package shapefile provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling the shapes in ESRI Shapefiles as GeoJSON Features. The package registers itself with the "shapefile"
+// scheme so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/shapefile"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "shapefile://")
+//
+//		for rec, _ := range it.Iterate(ctx, "/usr/local/data/boundaries.shp") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+//
+// Geometries are read from the ".shp" file and attributes from the ".dbf" file with the same name. Attribute values are
+// decoded using the character encoding named in the ".cpg" file, if present. Only the X and Y coordinates of geometries
+// are decoded and they are not reprojected.
+package shapefile
@@ -0,0 +1,75 @@
+package shapefile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// DEFAULT_ENCODING is the character encoding used to decode ".dbf" files when there is no corresponding ".cpg" file.
+const DEFAULT_ENCODING string = "ISO-8859-1"
+
+// codePages maps (numeric) Windows code page identifiers, as commonly found in ".cpg" files, to encoding names.
+var codePages = map[int]string{
+	437:   "IBM437",
+	850:   "IBM850",
+	852:   "IBM852",
+	866:   "IBM866",
+	874:   "windows-874",
+	932:   "shift_jis",
+	936:   "gbk",
+	949:   "euc-kr",
+	950:   "big5",
+	1250:  "windows-1250",
+	1251:  "windows-1251",
+	1252:  "windows-1252",
+	1253:  "windows-1253",
+	1254:  "windows-1254",
+	1255:  "windows-1255",
+	1256:  "windows-1256",
+	1257:  "windows-1257",
+	1258:  "windows-1258",
+	65001: "utf-8",
+}
+
+// encodingForName returns the `encoding.Encoding` instance for 'name' which may be a WHATWG encoding label, an IANA
+// character set name or a numeric Windows code page identifier.
+func encodingForName(name string) (encoding.Encoding, error) {
+
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return nil, fmt.Errorf("Empty encoding name")
+	}
+
+	cp, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(name), "CP"))
+
+	if err == nil {
+
+		cp_name, ok := codePages[cp]
+
+		if !ok {
+			return nil, fmt.Errorf("Unsupported code page '%s'", name)
+		}
+
+		name = cp_name
+	}
+
+	enc, err := htmlindex.Get(name)
+
+	if err == nil {
+		return enc, nil
+	}
+
+	enc, err = ianaindex.IANA.Encoding(strings.ReplaceAll(name, " ", "-"))
+
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("Unsupported encoding '%s'", name)
+	}
+
+	return enc, nil
+}
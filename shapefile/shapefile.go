@@ -0,0 +1,342 @@
+package shapefile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/paulmach/orb/geojson"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "shapefile", NewShapefileIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ShapefileIterator implements the `Iterator` interface for crawling the shapes in ESRI Shapefiles as GeoJSON Features.
+type ShapefileIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// encoding is the name of the character encoding used to decode attributes when a shapefile has no ".cpg" file.
+	encoding string
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewShapefileIterator() returns a new `ShapefileIterator` instance configured by 'uri' in the form of:
+//
+//	shapefile://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?encoding=` The character encoding used to decode attributes when a shapefile has no ".cpg" file. (Default is ISO-8859-1.)
+//
+// Each shape is converted in to a GeoJSON Feature whose properties are the values of the corresponding record in the
+// ".dbf" file. Records marked as deleted in the ".dbf" file are skipped. The `Path` property of each record is the URI of
+// the ".shp" file followed by "#" and the (zero-based) index of the shape.
+func NewShapefileIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	enc := DEFAULT_ENCODING
+
+	if q.Has("encoding") {
+
+		enc = q.Get("encoding")
+
+		_, err := encodingForName(enc)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'encoding' parameter, %w", err)
+		}
+	}
+
+	it := &ShapefileIterator{
+		filters:   f,
+		encoding:  enc,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *ShapefileIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateFile(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateFile yields records for each shape in the shapefile at 'uri'. It returns false if 'yield' has signaled
+// that iteration should stop.
+func (it *ShapefileIterator) iterateFile(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	shp_fh, err := os.Open(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	defer shp_fh.Close()
+
+	shp, err := newShpReader(bufio.NewReader(shp_fh))
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to read '%s', %w", uri, err))
+	}
+
+	prj, err := readSidecar(uri, ".prj")
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to read projection for '%s', %w", uri, err))
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(prj), []byte("PROJCS")) {
+		logger.Warn("Shapefile uses a projected coordinate system, coordinates will not be reprojected")
+	}
+
+	enc_name := it.encoding
+
+	cpg, err := readSidecar(uri, ".cpg")
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to read code page for '%s', %w", uri, err))
+	}
+
+	if len(bytes.TrimSpace(cpg)) > 0 {
+		enc_name = string(cpg)
+	}
+
+	enc, err := encodingForName(enc_name)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to determine character encoding for '%s', %w", uri, err))
+	}
+
+	var dbf *dbfReader
+
+	dbf_path, err := findSidecar(uri, ".dbf")
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to find attributes for '%s', %w", uri, err))
+	}
+
+	if dbf_path != "" {
+
+		dbf_fh, err := os.Open(dbf_path)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to open '%s', %w", dbf_path, err))
+		}
+
+		defer dbf_fh.Close()
+
+		dbf, err = newDbfReader(bufio.NewReader(dbf_fh), enc)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to read '%s', %w", dbf_path, err))
+		}
+
+	} else {
+		logger.Warn("Shapefile has no attributes file, features will have empty properties")
+	}
+
+	for i := 0; ; i++ {
+
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			// pass
+		}
+
+		path := fmt.Sprintf("%s#%d", uri, i)
+
+		_, geom, err := shp.Next()
+
+		if err == io.EOF {
+			return true
+		}
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to read shape at '%s', %w", path, err))
+		}
+
+		props := make(map[string]any)
+
+		if dbf != nil {
+
+			var deleted bool
+
+			props, deleted, err = dbf.Next()
+
+			if err == io.EOF {
+				return yield(nil, fmt.Errorf("Failed to read attributes at '%s', attributes file has fewer records than shapes", path))
+			}
+
+			if err != nil {
+				return yield(nil, fmt.Errorf("Failed to read attributes at '%s', %w", path, err))
+			}
+
+			if deleted {
+				logger.Debug("Skip deleted record", "path", path)
+				continue
+			}
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		f := geojson.NewFeature(geom)
+		f.Properties = props
+
+		body, err := f.MarshalJSON()
+
+		if err != nil {
+			if !yield(nil, fmt.Errorf("Failed to marshal '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		br := bytes.NewReader(body)
+		rsc, err := ioutil.NewReadSeekCloser(br)
+
+		if err != nil {
+			if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		if it.filters != nil {
+
+			ok, err := iterate.ApplyFilters(ctx, rsc, it.filters)
+
+			if err != nil {
+				rsc.Close()
+
+				if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
+					return false
+				}
+
+				continue
+			}
+
+			if !ok {
+				rsc.Close()
+				continue
+			}
+		}
+
+		rec := iterate.NewRecord(path, rsc)
+
+		if !yield(rec, nil) {
+			return false
+		}
+	}
+}
+
+// findSidecar returns the path of the file with the same name as 'uri' and the extension 'ext' (in either lower or
+// upper case) or an empty string if it does not exist.
+func findSidecar(uri string, ext string) (string, error) {
+
+	base := strings.TrimSuffix(uri, filepath.Ext(uri))
+
+	for _, candidate := range []string{base + strings.ToLower(ext), base + strings.ToUpper(ext)} {
+
+		_, err := os.Stat(candidate)
+
+		if err == nil {
+			return candidate, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// readSidecar returns the contents of the file with the same name as 'uri' and the extension 'ext' or nil if it does not exist.
+func readSidecar(uri string, ext string) ([]byte, error) {
+
+	path, err := findSidecar(uri, ext)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	return os.ReadFile(path)
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *ShapefileIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *ShapefileIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *ShapefileIterator) Close() error {
+	return nil
+}
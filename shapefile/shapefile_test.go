@@ -0,0 +1,406 @@
+package shapefile
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+type testShape struct {
+	shape_type int32
+	// parts is the list of parts for the shape. Rings are expected to follow shapefile ordering conventions (exterior
+	// rings clockwise, holes counter-clockwise).
+	parts   [][]orb.Point
+	name    []byte
+	pop     string
+	deleted bool
+}
+
+// writeTestShapefile writes 'shapes' to a ".shp" file named 'name' and a corresponding ".dbf" file in 'dir'.
+func writeTestShapefile(t *testing.T, dir string, name string, shape_type int32, shapes []*testShape) string {
+
+	t.Helper()
+
+	// Geometries
+
+	records := new(bytes.Buffer)
+
+	for i, s := range shapes {
+
+		content := new(bytes.Buffer)
+		binary.Write(content, binary.LittleEndian, s.shape_type)
+
+		switch s.shape_type {
+		case shapeTypeNull:
+			// pass
+		case shapeTypePoint:
+			binary.Write(content, binary.LittleEndian, s.parts[0][0][0])
+			binary.Write(content, binary.LittleEndian, s.parts[0][0][1])
+		default:
+
+			points := make([]orb.Point, 0)
+			offsets := make([]int32, 0)
+
+			for _, p := range s.parts {
+				offsets = append(offsets, int32(len(points)))
+				points = append(points, p...)
+			}
+
+			bbox := orb.MultiPoint(points).Bound()
+
+			for _, v := range []float64{bbox.Min[0], bbox.Min[1], bbox.Max[0], bbox.Max[1]} {
+				binary.Write(content, binary.LittleEndian, v)
+			}
+
+			binary.Write(content, binary.LittleEndian, int32(len(offsets)))
+			binary.Write(content, binary.LittleEndian, int32(len(points)))
+			binary.Write(content, binary.LittleEndian, offsets)
+
+			for _, pt := range points {
+				binary.Write(content, binary.LittleEndian, pt[0])
+				binary.Write(content, binary.LittleEndian, pt[1])
+			}
+		}
+
+		binary.Write(records, binary.BigEndian, int32(i+1))
+		binary.Write(records, binary.BigEndian, int32(content.Len()/2))
+		records.Write(content.Bytes())
+	}
+
+	shp := new(bytes.Buffer)
+	binary.Write(shp, binary.BigEndian, SHP_FILE_CODE)
+	shp.Write(make([]byte, 20))
+	binary.Write(shp, binary.BigEndian, int32((SHP_HEADER_SIZE+records.Len())/2))
+	binary.Write(shp, binary.LittleEndian, int32(1000))
+	binary.Write(shp, binary.LittleEndian, shape_type)
+	shp.Write(make([]byte, 64))
+	shp.Write(records.Bytes())
+
+	// Attributes
+
+	type field struct {
+		name     string
+		typ      byte
+		length   int
+		decimals int
+	}
+
+	fields := []*field{
+		{name: "name", typ: 'C', length: 20},
+		{name: "pop", typ: 'N', length: 10},
+		{name: "updated", typ: 'D', length: 8},
+	}
+
+	record_length := 1
+
+	for _, f := range fields {
+		record_length += f.length
+	}
+
+	dbf := new(bytes.Buffer)
+	dbf.Write([]byte{0x03, 125, 1, 1})
+	binary.Write(dbf, binary.LittleEndian, uint32(len(shapes)))
+	binary.Write(dbf, binary.LittleEndian, uint16(DBF_HEADER_SIZE+len(fields)*DBF_FIELD_SIZE+1))
+	binary.Write(dbf, binary.LittleEndian, uint16(record_length))
+	dbf.Write(make([]byte, 20))
+
+	for _, f := range fields {
+		desc := make([]byte, DBF_FIELD_SIZE)
+		copy(desc, f.name)
+		desc[11] = f.typ
+		desc[16] = byte(f.length)
+		desc[17] = byte(f.decimals)
+		dbf.Write(desc)
+	}
+
+	dbf.WriteByte(0x0D)
+
+	for _, s := range shapes {
+
+		if s.deleted {
+			dbf.WriteByte('*')
+		} else {
+			dbf.WriteByte(' ')
+		}
+
+		dbf.Write(pad(s.name, 20))
+		dbf.Write(bytes.Repeat([]byte(" "), 10-len(s.pop)))
+		dbf.WriteString(s.pop)
+		dbf.WriteString("20240131")
+	}
+
+	dbf.WriteByte(0x1A)
+
+	path := filepath.Join(dir, name+".shp")
+
+	err := os.WriteFile(path, shp.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	dbf_path := filepath.Join(dir, name+".dbf")
+
+	err = os.WriteFile(dbf_path, dbf.Bytes(), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", dbf_path, err)
+	}
+
+	return path
+}
+
+func pad(b []byte, length int) []byte {
+	return append(b, bytes.Repeat([]byte(" "), length-len(b))...)
+}
+
+// square returns a closed square ring with sides of 'size' whose lower left corner is 'x', 'y' in clockwise order.
+func square(x float64, y float64, size float64) []orb.Point {
+	return []orb.Point{{x, y}, {x, y + size}, {x + size, y + size}, {x + size, y}, {x, y}}
+}
+
+// reversed returns a copy of 'pts' in reverse order.
+func reversed(pts []orb.Point) []orb.Point {
+
+	r := make([]orb.Point, len(pts))
+
+	for i, pt := range pts {
+		r[len(pts)-1-i] = pt
+	}
+
+	return r
+}
+
+type testFeature struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func iterateFeatures(t *testing.T, iter_uri string, path string) map[string]*testFeature {
+
+	t.Helper()
+
+	ctx := context.Background()
+
+	it, err := iterate.NewIterator(ctx, iter_uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create new shapefile source for '%s', %v", iter_uri, err)
+	}
+
+	features := make(map[string]*testFeature)
+
+	for rec, err := range it.Iterate(ctx, path) {
+
+		if err != nil {
+			t.Fatalf("Failed to iterate '%s' with '%s', %v", path, iter_uri, err)
+		}
+
+		body, err := io.ReadAll(rec.Body)
+		rec.Body.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		var f testFeature
+
+		err = json.Unmarshal(body, &f)
+
+		if err != nil {
+			t.Fatalf("Failed to unmarshal %s, %v", rec.Path, err)
+		}
+
+		if f.Type != "Feature" {
+			t.Fatalf("Invalid feature for %s, %s", rec.Path, string(body))
+		}
+
+		features[rec.Path] = &f
+	}
+
+	return features
+}
+
+func TestShapefileIteratorPolygons(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// Zürich encoded as windows-1252
+	zurich := []byte{0x5a, 0xfc, 0x72, 0x69, 0x63, 0x68}
+
+	shapes := []*testShape{
+		// A polygon with a hole
+		{shape_type: shapeTypePolygon, parts: [][]orb.Point{square(0, 0, 10), reversed(square(2, 2, 2))}, name: zurich, pop: "400000"},
+		// A multipart polygon whose hole precedes its exterior ring
+		{shape_type: shapeTypePolygon, parts: [][]orb.Point{square(20, 20, 10), reversed(square(42, 42, 2)), square(40, 40, 10)}, name: []byte("multi"), pop: "12"},
+		// A deleted record
+		{shape_type: shapeTypePolygon, parts: [][]orb.Point{square(60, 60, 1)}, name: []byte("deleted"), pop: "1", deleted: true},
+		// A null shape
+		{shape_type: shapeTypeNull, name: []byte("null"), pop: ""},
+	}
+
+	path := writeTestShapefile(t, dir, "polygons", shapeTypePolygon, shapes)
+
+	cpg_path := filepath.Join(dir, "polygons.cpg")
+
+	err := os.WriteFile(cpg_path, []byte("1252\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", cpg_path, err)
+	}
+
+	features := iterateFeatures(t, "shapefile://", path)
+
+	if len(features) != 3 {
+		t.Fatalf("Unexpected record count. Got %d but expected 3", len(features))
+	}
+
+	f, ok := features[fmt.Sprintf("%s#0", path)]
+
+	if !ok {
+		t.Fatalf("Missing first feature")
+	}
+
+	if f.Properties["name"] != "Zürich" || f.Properties["pop"] != float64(400000) || f.Properties["updated"] != "2024-01-31" {
+		t.Fatalf("Unexpected properties for first feature, %v", f.Properties)
+	}
+
+	var poly [][][]float64
+
+	err = json.Unmarshal(f.Geometry.Coordinates, &poly)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal coordinates, %v", err)
+	}
+
+	if f.Geometry.Type != "Polygon" || len(poly) != 2 {
+		t.Fatalf("Expected polygon with interior ring, got %s with %d rings", f.Geometry.Type, len(poly))
+	}
+
+	// Exterior rings should be counter-clockwise (RFC 7946)
+
+	ring := make(orb.Ring, len(poly[0]))
+
+	for i, pt := range poly[0] {
+		ring[i] = orb.Point{pt[0], pt[1]}
+	}
+
+	if ring.Orientation() != orb.CCW {
+		t.Fatalf("Expected exterior ring to be counter-clockwise")
+	}
+
+	f, ok = features[fmt.Sprintf("%s#1", path)]
+
+	if !ok {
+		t.Fatalf("Missing second feature")
+	}
+
+	var mp [][][][]float64
+
+	err = json.Unmarshal(f.Geometry.Coordinates, &mp)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal coordinates, %v", err)
+	}
+
+	if f.Geometry.Type != "MultiPolygon" || len(mp) != 2 || len(mp[0]) != 1 || len(mp[1]) != 2 {
+		t.Fatalf("Unexpected multipolygon, %s %v", f.Geometry.Type, mp)
+	}
+
+	f, ok = features[fmt.Sprintf("%s#3", path)]
+
+	if !ok {
+		t.Fatalf("Missing null shape")
+	}
+
+	if f.Geometry != nil || f.Properties["pop"] != nil {
+		t.Fatalf("Unexpected null shape, %v", f)
+	}
+
+	features = iterateFeatures(t, "shapefile://?include=properties.name=^multi$", path)
+
+	if len(features) != 1 {
+		t.Fatalf("Unexpected record count with filters. Got %d but expected 1", len(features))
+	}
+}
+
+func TestShapefileIteratorPolyLines(t *testing.T) {
+
+	dir := t.TempDir()
+
+	shapes := []*testShape{
+		{shape_type: shapeTypePolyLine, parts: [][]orb.Point{{{0, 0}, {1, 1}}}, name: []byte("Montréal"), pop: "1"},
+		{shape_type: shapeTypePolyLine, parts: [][]orb.Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}, {4, 4}}}, name: []byte("multi"), pop: "2"},
+	}
+
+	path := writeTestShapefile(t, dir, "lines", shapeTypePolyLine, shapes)
+
+	// No .cpg file so the encoding must be specified explicitly
+
+	features := iterateFeatures(t, "shapefile://?encoding=utf-8", path)
+
+	if len(features) != 2 {
+		t.Fatalf("Unexpected record count. Got %d but expected 2", len(features))
+	}
+
+	f := features[fmt.Sprintf("%s#0", path)]
+
+	if f.Geometry.Type != "LineString" || f.Properties["name"] != "Montréal" {
+		t.Fatalf("Unexpected first feature, %s %v", f.Geometry.Type, f.Properties)
+	}
+
+	f = features[fmt.Sprintf("%s#1", path)]
+
+	if f.Geometry.Type != "MultiLineString" {
+		t.Fatalf("Unexpected geometry type for second feature, %s", f.Geometry.Type)
+	}
+}
+
+func TestEncodingForName(t *testing.T) {
+
+	for _, name := range []string{"UTF-8", "utf8", "1252", "CP1252", "ISO-8859-1", "ISO 8859-1", "windows-1251", "65001", "437"} {
+
+		_, err := encodingForName(name)
+
+		if err != nil {
+			t.Fatalf("Failed to derive encoding for '%s', %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "12345", "not-an-encoding"} {
+
+		_, err := encodingForName(name)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", name)
+		}
+	}
+}
+
+func TestDecodeShapeInvalid(t *testing.T) {
+
+	buf := make([]byte, 44)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(shapeTypePolygon))
+	binary.LittleEndian.PutUint32(buf[36:40], 1)
+	binary.LittleEndian.PutUint32(buf[40:44], math.MaxUint32)
+
+	_, err := decodeShape(buf)
+
+	if err == nil {
+		t.Fatalf("Expected truncated polygon to fail")
+	}
+}
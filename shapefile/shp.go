@@ -0,0 +1,309 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// SHP_FILE_CODE is the value of the first four (big-endian) bytes of a ".shp" file.
+const SHP_FILE_CODE int32 = 9994
+
+// SHP_HEADER_SIZE is the size, in bytes, of the header of a ".shp" file.
+const SHP_HEADER_SIZE int = 100
+
+// Shape types defined by the ESRI Shapefile specification. The "Z" and "M" variants of each type are decoded as
+// their two-dimensional equivalents.
+const (
+	shapeTypeNull        int32 = 0
+	shapeTypePoint       int32 = 1
+	shapeTypePolyLine    int32 = 3
+	shapeTypePolygon     int32 = 5
+	shapeTypeMultiPoint  int32 = 8
+	shapeTypePointZ      int32 = 11
+	shapeTypePolyLineZ   int32 = 13
+	shapeTypePolygonZ    int32 = 15
+	shapeTypeMultiPointZ int32 = 18
+	shapeTypePointM      int32 = 21
+	shapeTypePolyLineM   int32 = 23
+	shapeTypePolygonM    int32 = 25
+	shapeTypeMultiPointM int32 = 28
+)
+
+// shpReader reads the records in a ".shp" file sequentially.
+type shpReader struct {
+	reader io.Reader
+	// shape_type is the shape type declared in the file's header.
+	shape_type int32
+}
+
+// newShpReader reads the header of the ".shp" file in 'r' and returns a new `shpReader` instance for reading its records.
+func newShpReader(r io.Reader) (*shpReader, error) {
+
+	header := make([]byte, SHP_HEADER_SIZE)
+
+	_, err := io.ReadFull(r, header)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read header, %w", err)
+	}
+
+	file_code := int32(binary.BigEndian.Uint32(header[0:4]))
+
+	if file_code != SHP_FILE_CODE {
+		return nil, fmt.Errorf("Invalid file code %d, not a shapefile", file_code)
+	}
+
+	s := &shpReader{
+		reader:     r,
+		shape_type: int32(binary.LittleEndian.Uint32(header[32:36])),
+	}
+
+	return s, nil
+}
+
+// Next returns the (one-based) record number and geometry of the next record in the file. The geometry will be nil for
+// null shapes. It returns `io.EOF` if there are no more records to read.
+func (s *shpReader) Next() (int32, orb.Geometry, error) {
+
+	header := make([]byte, 8)
+
+	_, err := io.ReadFull(s.reader, header)
+
+	if err != nil {
+
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+
+		return 0, nil, fmt.Errorf("Failed to read record header, %w", err)
+	}
+
+	number := int32(binary.BigEndian.Uint32(header[0:4]))
+
+	// Content length is measured in 16-bit words
+	length := int64(binary.BigEndian.Uint32(header[4:8])) * 2
+
+	if length < 4 || length > math.MaxInt32 {
+		return 0, nil, fmt.Errorf("Invalid content length %d for record %d", length, number)
+	}
+
+	content := make([]byte, length)
+
+	_, err = io.ReadFull(s.reader, content)
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to read record %d, %w", number, err)
+	}
+
+	geom, err := decodeShape(content)
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to decode record %d, %w", number, err)
+	}
+
+	return number, geom, nil
+}
+
+// decodeShape returns the `orb.Geometry` representation of the record content in 'buf'.
+func decodeShape(buf []byte) (orb.Geometry, error) {
+
+	shape_type := int32(binary.LittleEndian.Uint32(buf[0:4]))
+
+	switch shape_type {
+	case shapeTypeNull:
+		return nil, nil
+
+	case shapeTypePoint, shapeTypePointZ, shapeTypePointM:
+
+		if len(buf) < 20 {
+			return nil, fmt.Errorf("Invalid point, record too short")
+		}
+
+		return readPoint(buf, 4), nil
+
+	case shapeTypeMultiPoint, shapeTypeMultiPointZ, shapeTypeMultiPointM:
+
+		if len(buf) < 40 {
+			return nil, fmt.Errorf("Invalid multipoint, record too short")
+		}
+
+		num_points := int64(binary.LittleEndian.Uint32(buf[36:40]))
+
+		if 40+num_points*16 > int64(len(buf)) {
+			return nil, fmt.Errorf("Invalid multipoint, record too short for %d points", num_points)
+		}
+
+		mp := make(orb.MultiPoint, num_points)
+
+		for i := range mp {
+			mp[i] = readPoint(buf, 40+i*16)
+		}
+
+		return mp, nil
+
+	case shapeTypePolyLine, shapeTypePolyLineZ, shapeTypePolyLineM:
+
+		parts, err := readParts(buf)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid polyline, %w", err)
+		}
+
+		switch len(parts) {
+		case 0:
+			return nil, nil
+		case 1:
+			return orb.LineString(parts[0]), nil
+		default:
+
+			mls := make(orb.MultiLineString, len(parts))
+
+			for i, pts := range parts {
+				mls[i] = orb.LineString(pts)
+			}
+
+			return mls, nil
+		}
+
+	case shapeTypePolygon, shapeTypePolygonZ, shapeTypePolygonM:
+
+		parts, err := readParts(buf)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid polygon, %w", err)
+		}
+
+		rings := make([]orb.Ring, len(parts))
+
+		for i, pts := range parts {
+			rings[i] = orb.Ring(pts)
+		}
+
+		return assemblePolygons(rings), nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported shape type %d", shape_type)
+	}
+}
+
+// readPoint returns the point encoded at 'offset' in 'buf'.
+func readPoint(buf []byte, offset int) orb.Point {
+	x := math.Float64frombits(binary.LittleEndian.Uint64(buf[offset : offset+8]))
+	y := math.Float64frombits(binary.LittleEndian.Uint64(buf[offset+8 : offset+16]))
+	return orb.Point{x, y}
+}
+
+// readParts returns the lists of points for each part of the polyline or polygon record content in 'buf'.
+func readParts(buf []byte) ([][]orb.Point, error) {
+
+	if len(buf) < 44 {
+		return nil, fmt.Errorf("Record too short")
+	}
+
+	num_parts := int64(binary.LittleEndian.Uint32(buf[36:40]))
+	num_points := int64(binary.LittleEndian.Uint32(buf[40:44]))
+
+	points_offset := 44 + num_parts*4
+
+	if points_offset+num_points*16 > int64(len(buf)) {
+		return nil, fmt.Errorf("Record too short for %d parts and %d points", num_parts, num_points)
+	}
+
+	parts := make([][]orb.Point, 0, num_parts)
+
+	for i := int64(0); i < num_parts; i++ {
+
+		start := int64(binary.LittleEndian.Uint32(buf[44+i*4:]))
+		end := num_points
+
+		if i+1 < num_parts {
+			end = int64(binary.LittleEndian.Uint32(buf[44+(i+1)*4:]))
+		}
+
+		if start > end || end > num_points {
+			return nil, fmt.Errorf("Invalid part %d (%d-%d)", i, start, end)
+		}
+
+		pts := make([]orb.Point, 0, end-start)
+
+		for j := start; j < end; j++ {
+			pts = append(pts, readPoint(buf, int(points_offset+j*16)))
+		}
+
+		parts = append(parts, pts)
+	}
+
+	return parts, nil
+}
+
+// assemblePolygons groups 'rings' in to polygons. Shapefiles store the exterior rings of polygons in clockwise order
+// and holes in counter-clockwise order without recording which exterior ring a hole belongs to so each hole is assigned
+// to the smallest exterior ring that contains it. Holes that are not contained by any exterior ring are treated as
+// exterior rings. Rings are reoriented to follow the right-hand rule (exterior rings counter-clockwise, holes clockwise)
+// described in RFC 7946. The result is an `orb.Polygon` if there is only one exterior ring, otherwise an `orb.MultiPolygon`.
+func assemblePolygons(rings []orb.Ring) orb.Geometry {
+
+	polys := make([]orb.Polygon, 0)
+	areas := make([]float64, 0)
+	holes := make([]orb.Ring, 0)
+
+	for _, r := range rings {
+
+		if len(r) == 0 {
+			continue
+		}
+
+		if r.Orientation() == orb.CCW {
+			holes = append(holes, r)
+			continue
+		}
+
+		r.Reverse()
+
+		polys = append(polys, orb.Polygon{r})
+		areas = append(areas, math.Abs(planar.Area(r)))
+	}
+
+	// Assign holes after all the exterior rings have been collected since a hole may
+	// precede its exterior ring
+
+	outers := len(polys)
+
+	for _, h := range holes {
+
+		idx := -1
+
+		for i := 0; i < outers; i++ {
+
+			if !polys[i][0].Bound().Contains(h[0]) || !planar.RingContains(polys[i][0], h[0]) {
+				continue
+			}
+
+			if idx == -1 || areas[i] < areas[idx] {
+				idx = i
+			}
+		}
+
+		if idx == -1 {
+			polys = append(polys, orb.Polygon{h})
+			continue
+		}
+
+		h.Reverse()
+		polys[idx] = append(polys[idx], h)
+	}
+
+	switch len(polys) {
+	case 0:
+		return nil
+	case 1:
+		return polys[0]
+	default:
+		return orb.MultiPolygon(polys)
+	}
+}
@@ -0,0 +1,24 @@
+// Package sqlite provides an implementation of the `whosonfirst/go-whosonfirst-iterate/v3.Iterator` interface for
+// crawling records stored in the `geojson` table of a Who's On First style SQLite database. Databases are read using
+// the `ncruces/go-sqlite3` driver, which runs SQLite as a WebAssembly module, so cgo is not required. The package
+// registers itself with the "sqlite" scheme so it needs to be imported explicitly. For example:
+//
+//	import (
+//		"context"
+//
+//		_ "github.com/whosonfirst/go-whosonfirst-iterate/v3/sqlite"
+//
+//		"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+//	)
+//
+//	func main() {
+//
+//		ctx := context.Background()
+//		it, _ := iterate.NewIterator(ctx, "sqlite://?is_alt=false")
+//
+//		for rec, _ := range it.Iterate(ctx, "/usr/local/data/whosonfirst-data-admin-us-latest.db") {
+//			defer rec.Body.Close()
+//			// do something with rec here
+//		}
+//	}
+package sqlite
@@ -0,0 +1,308 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/internal/relpath"
+)
+
+func init() {
+	ctx := context.Background()
+	err := iterate.RegisterIterator(ctx, "sqlite", NewSQLiteIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SQLiteIterator implements the `Iterator` interface for crawling records stored in the `geojson` table of a
+// Who's On First style SQLite database.
+type SQLiteIterator struct {
+	iterate.Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// is_alt is an optional boolean value used to limit records to (or exclude) alternate geometries.
+	is_alt *bool
+	// where is an optional SQL expression used to limit the records that are crawled.
+	where string
+	// ids is an optional list of Who's On First IDs used to limit the records that are crawled.
+	ids []int64
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewSQLiteIterator() returns a new `SQLiteIterator` instance configured by 'uri' in the form of:
+//
+//	sqlite://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?is_alt=` An optional boolean value used to limit records to (true) or exclude (false) alternate geometries.
+// * `?where=` An optional SQL expression, appended to the query's WHERE clause, used to limit the records that are crawled.
+// * `?id=` Zero or more Who's On First IDs (or comma-separated lists of IDs) used to limit the records that are crawled.
+//
+// URIs passed to the `Iterate` method are expected to be the paths of SQLite databases which are opened read-only.
+// The `Path` property of each record is derived from its ID (and alternate geometry label) using the
+// `whosonfirst/go-whosonfirst-uri.Id2RelPath` method.
+func NewSQLiteIterator(ctx context.Context, uri string) (iterate.Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	it := &SQLiteIterator{
+		filters:   f,
+		where:     q.Get("where"),
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	if q.Has("is_alt") {
+
+		v, err := strconv.ParseBool(q.Get("is_alt"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'is_alt' parameter, %w", err)
+		}
+
+		it.is_alt = &v
+	}
+
+	for _, str_ids := range q["id"] {
+
+		for _, str_id := range strings.Split(str_ids, ",") {
+
+			str_id = strings.TrimSpace(str_id)
+
+			if str_id == "" {
+				continue
+			}
+
+			id, err := strconv.ParseInt(str_id, 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse 'id' parameter, %w", err)
+			}
+
+			it.ids = append(it.ids, id)
+		}
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *SQLiteIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*iterate.Record, error] {
+
+	return func(yield func(rec *iterate.Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateDatabase(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateDatabase yields records for each matching row in the `geojson` table of the database at 'uri'. It
+// returns false if 'yield' has signaled that iteration should stop.
+func (it *SQLiteIterator) iterateDatabase(ctx context.Context, uri string, yield func(rec *iterate.Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	abs_path, err := filepath.Abs(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err))
+	}
+
+	dsn_u := url.URL{
+		Scheme:   "file",
+		Path:     abs_path,
+		RawQuery: "mode=ro",
+	}
+
+	db, err := sql.Open("sqlite3", dsn_u.String())
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	defer db.Close()
+
+	q, args := it.query()
+	logger.Debug("Query database", "query", q)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to query '%s', %w", uri, err))
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var id int64
+		var body []byte
+		var is_alt sql.NullBool
+		var alt_label sql.NullString
+
+		err := rows.Scan(&id, &body, &is_alt, &alt_label)
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to scan row in '%s', %w", uri, err))
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		label := ""
+
+		if is_alt.Bool {
+			label = alt_label.String
+		}
+
+		path, err := relpath.FromId(id, label)
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to derive path for %d in '%s', %w", id, uri, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		br := bytes.NewReader(body)
+		rsc, err := ioutil.NewReadSeekCloser(br)
+
+		if err != nil {
+
+			if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
+				return false
+			}
+
+			continue
+		}
+
+		if it.filters != nil {
+
+			ok, err := iterate.ApplyFilters(ctx, rsc, it.filters)
+
+			if err != nil {
+				rsc.Close()
+
+				if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
+					return false
+				}
+
+				continue
+			}
+
+			if !ok {
+				rsc.Close()
+				continue
+			}
+		}
+
+		rec := iterate.NewRecord(path, rsc)
+
+		if !yield(rec, nil) {
+			return false
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to iterate rows in '%s', %w", uri, err))
+	}
+
+	return true
+}
+
+// query returns the SQL query, and its arguments, used to select records from the `geojson` table.
+func (it *SQLiteIterator) query() (string, []any) {
+
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	if it.is_alt != nil {
+		conditions = append(conditions, "is_alt = ?")
+		args = append(args, *it.is_alt)
+	}
+
+	if len(it.ids) > 0 {
+
+		placeholders := make([]string, len(it.ids))
+
+		for i, id := range it.ids {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+
+		conditions = append(conditions, fmt.Sprintf("id IN (%s)", strings.Join(placeholders, ",")))
+	}
+
+	if it.where != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s)", it.where))
+	}
+
+	q := "SELECT id, body, is_alt, alt_label FROM geojson"
+
+	if len(conditions) > 0 {
+		q = fmt.Sprintf("%s WHERE %s", q, strings.Join(conditions, " AND "))
+	}
+
+	return q, args
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *SQLiteIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *SQLiteIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *SQLiteIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+// newTestDatabase creates a new Who's On First style SQLite database in a temporary directory containing the
+// records in the "fixtures/data" directory as well as an alternate geometry for one of them.
+func newTestDatabase(t *testing.T) string {
+
+	t.Helper()
+
+	db_path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite3", db_path)
+
+	if err != nil {
+		t.Fatalf("Failed to open database, %v", err)
+	}
+
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE geojson (id INTEGER NOT NULL, body TEXT, source TEXT, is_alt BOOLEAN, alt_label TEXT, lastmodified INTEGER)")
+
+	if err != nil {
+		t.Fatalf("Failed to create table, %v", err)
+	}
+
+	root := "../fixtures/data"
+
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		id, err := uri.IdFromPath(path)
+
+		if err != nil {
+			return err
+		}
+
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("INSERT INTO geojson (id, body, source, is_alt, alt_label, lastmodified) VALUES (?, ?, ?, ?, ?, ?)", id, string(body), "sfomuseum", false, "", 0)
+
+		if err != nil {
+			return err
+		}
+
+		if id == 1746574207 {
+			_, err = db.Exec("INSERT INTO geojson (id, body, source, is_alt, alt_label, lastmodified) VALUES (?, ?, ?, ?, ?, ?)", id, string(body), "quattroshapes", true, "quattroshapes", 0)
+		}
+
+		return err
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to populate database, %v", err)
+	}
+
+	return db_path
+}
+
+func TestSQLiteIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	db_path := newTestDatabase(t)
+
+	tests := map[string]int64{
+		"sqlite://":                                         38,
+		"sqlite://?is_alt=false":                            37,
+		"sqlite://?is_alt=true":                             1,
+		"sqlite://?_exclude_alt=true":                       37,
+		"sqlite://?id=1746574207":                           2,
+		"sqlite://?id=1746574207,1746124347&is_alt=false":   2,
+		"sqlite://?where=id%20%3E%201746000000":             4,
+		"sqlite://?exclude=properties.wof:placetype=custom": 0,
+		"sqlite://?include=properties.wof:id=1746574207":    2,
+	}
+
+	for iter_uri, expected := range tests {
+
+		it, err := iterate.NewIterator(ctx, iter_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new sqlite source for '%s', %v", iter_uri, err)
+		}
+
+		count := int64(0)
+
+		for rec, err := range it.Iterate(ctx, db_path) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate '%s' with '%s', %v", db_path, iter_uri, err)
+			}
+
+			count += 1
+
+			defer rec.Body.Close()
+
+			_, err = io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			id, err := uri.IdFromPath(rec.Path)
+
+			if err != nil {
+				t.Fatalf("Failed to derive ID from path %s, %v", rec.Path, err)
+			}
+
+			expected_path, err := uri.Id2RelPath(id)
+
+			if err != nil {
+				t.Fatalf("Failed to derive path for %d, %v", id, err)
+			}
+
+			is_alt, err := uri.IsAltFile(rec.Path)
+
+			if err != nil {
+				t.Fatalf("Failed to determine if %s is an alt file, %v", rec.Path, err)
+			}
+
+			if !is_alt && rec.Path != expected_path {
+				t.Fatalf("Unexpected path. Got %s but expected %s", rec.Path, expected_path)
+			}
+		}
+
+		if count != expected {
+			t.Fatalf("Unexpected record count for '%s'. Got %d but expected %d", iter_uri, count, expected)
+		}
+	}
+}
+
+func TestSQLiteIteratorMissingTable(t *testing.T) {
+
+	ctx := context.Background()
+
+	db_path := filepath.Join(t.TempDir(), "empty.db")
+
+	db, err := sql.Open("sqlite3", db_path)
+
+	if err != nil {
+		t.Fatalf("Failed to open database, %v", err)
+	}
+
+	_, err = db.Exec("CREATE TABLE example (id INTEGER)")
+
+	if err != nil {
+		t.Fatalf("Failed to create table, %v", err)
+	}
+
+	db.Close()
+
+	it, err := iterate.NewIterator(ctx, "sqlite://")
+
+	if err != nil {
+		t.Fatalf("Failed to create new sqlite source, %v", err)
+	}
+
+	for _, err := range it.Iterate(ctx, db_path) {
+
+		if err == nil {
+			t.Fatalf("Expected iterating a database without a geojson table to fail")
+		}
+	}
+
+	if it.Seen() != 0 {
+		t.Fatalf("Unexpected record count %d", it.Seen())
+	}
+}
@@ -0,0 +1,212 @@
+package iterate
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "tar", NewTarIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TarIterator implements the `Iterator` interface for crawling records in a tar archive. Archives compressed
+// using gzip, bzip2 or zstd are decompressed automatically.
+type TarIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// reader_options is a `ReaderOptions` instance used to configure how files are read.
+	reader_options *ReaderOptions
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewTarIterator() returns a new `TarIterator` instance configured by 'uri' in the form of:
+//
+//	tar://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?compression=` The compression scheme used to read each archive. Valid options are: auto, none, gzip, bzip2, zstd. (Default is auto.)
+// * `?access_token=` An optional bearer token to include with requests for remote (http:// or https://) files.
+// * `?header=` Zero or more additional HTTP headers, in the form of "{NAME}:{VALUE}", to include with requests for remote files.
+// * `?max_resume=` The maximum number of times to resume reading a remote file, using HTTP range requests, if a connection is interrupted. (Default is 3.)
+func NewTarIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	reader_options, err := NewReaderOptionsFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader options from query, %w", err)
+	}
+
+	it := &TarIterator{
+		filters:        f,
+		reader_options: reader_options,
+		seen:           int64(0),
+		iterating:      new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *TarIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			logger := slog.Default()
+			logger = logger.With("uri", uri)
+
+			r, err := ReaderWithPathAndOptions(ctx, uri, it.reader_options)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to create reader for '%s', %w", uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			defer r.Close()
+
+			tar_r := tar.NewReader(r)
+
+			for {
+
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					// pass
+				}
+
+				hdr, err := tar_r.Next()
+
+				if err == io.EOF {
+					break
+				}
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to read next entry in '%s', %w", uri, err)) {
+						return
+					}
+
+					break
+				}
+
+				if hdr.Typeflag != tar.TypeReg {
+					continue
+				}
+
+				path := hdr.Name
+				logger.Debug("Process entry", "path", path)
+
+				atomic.AddInt64(&it.seen, 1)
+
+				// Entries can only be read sequentially so the body of each record
+				// needs to be buffered before moving on to the next one.
+
+				body, err := io.ReadAll(tar_r)
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to read '%s' from '%s', %w", path, uri, err)) {
+						return
+					}
+
+					break
+				}
+
+				br := bytes.NewReader(body)
+				rsc, err := ioutil.NewReadSeekCloser(br)
+
+				if err != nil {
+					if !yield(nil, fmt.Errorf("Failed to create new ReadSeekCloser for '%s', %w", path, err)) {
+						return
+					}
+
+					continue
+				}
+
+				if it.filters != nil {
+
+					ok, err := ApplyFilters(ctx, rsc, it.filters)
+
+					if err != nil {
+						rsc.Close()
+						if !yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err)) {
+							return
+						}
+
+						continue
+					}
+
+					if !ok {
+						rsc.Close()
+						continue
+					}
+				}
+
+				rec := NewRecord(path, rsc)
+				rec.Info = hdr.FileInfo()
+
+				if !yield(rec, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *TarIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *TarIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *TarIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package iterate
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarIterator(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	abs_path, err := filepath.Abs("testdata/archives/data.tar.gz")
+
+	if err != nil {
+		t.Fatalf("Failed to derive absolute path for fixtures, %v", err)
+	}
+
+	it, err := NewIterator(ctx, "tar://?_exclude_alt=true")
+
+	if err != nil {
+		t.Fatalf("Failed to create new tar source, %v", err)
+	}
+
+	for rec, err := range it.Iterate(ctx, abs_path) {
+
+		if err != nil {
+			t.Fatalf("Failed to walk '%s', %v", abs_path, err)
+			break
+		}
+
+		defer rec.Body.Close()
+		_, err = io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+		}
+
+		_, err = rec.Body.Seek(0, 0)
+
+		if err != nil {
+			t.Fatalf("Failed to rewind body for %s, %v", rec.Path, err)
+		}
+
+		_, err = io.ReadAll(rec.Body)
+
+		if err != nil {
+			t.Fatalf("Failed second read body for %s, %v", rec.Path, err)
+		}
+	}
+
+	seen := it.Seen()
+	expected := int64(37)
+
+	if seen != expected {
+		t.Fatalf("Unexpected record count. Got %d but expected %d", seen, expected)
+	}
+
+	err = it.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close iterator")
+	}
+}
+
+func TestTarIteratorUncompressed(t *testing.T) {
+
+	ctx := context.Background()
+
+	tar_path := filepath.Join(t.TempDir(), "collection.tar")
+
+	wr, err := os.Create(tar_path)
+
+	if err != nil {
+		t.Fatalf("Failed to create %s, %v", tar_path, err)
+	}
+
+	tar_wr := tar.NewWriter(wr)
+
+	for _, rel_path := range []string{"collection.geojson", "collection.geojsonl"} {
+
+		body, err := os.ReadFile(filepath.Join("fixtures", rel_path))
+
+		if err != nil {
+			t.Fatalf("Failed to read %s, %v", rel_path, err)
+		}
+
+		hdr := &tar.Header{
+			Name: rel_path,
+			Mode: 0644,
+			Size: int64(len(body)),
+		}
+
+		err = tar_wr.WriteHeader(hdr)
+
+		if err != nil {
+			t.Fatalf("Failed to write header for %s, %v", rel_path, err)
+		}
+
+		_, err = tar_wr.Write(body)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", rel_path, err)
+		}
+	}
+
+	err = tar_wr.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close tar writer, %v", err)
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close %s, %v", tar_path, err)
+	}
+
+	it, err := NewIterator(ctx, "tar://?_include=\\.geojson$")
+
+	if err != nil {
+		t.Fatalf("Failed to create new tar source, %v", err)
+	}
+
+	count := 0
+
+	for rec, err := range it.Iterate(ctx, tar_path) {
+
+		if err != nil {
+			t.Fatalf("Failed to walk '%s', %v", tar_path, err)
+		}
+
+		rec.Body.Close()
+
+		if rec.Path != "collection.geojson" {
+			t.Fatalf("Unexpected path '%s'", rec.Path)
+		}
+
+		count += 1
+	}
+
+	if count != 1 {
+		t.Fatalf("Expected 1 record, but counted %d", count)
+	}
+}
@@ -11,6 +11,8 @@ func ScrubURI(uri string) (string, error) {
 
 	to_scrub := []string{
 		"access_token",
+		"token",
+		"header",
 	}
 
 	u, err := url.Parse(uri)
@@ -19,6 +21,13 @@ func ScrubURI(uri string) (string, error) {
 		return "", fmt.Errorf("Failed to parse URI, %w", err)
 	}
 
+	if u.User != nil {
+
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "...")
+		}
+	}
+
 	q := u.Query()
 
 	for _, k := range to_scrub {
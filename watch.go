@@ -0,0 +1,754 @@
+package iterate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/whosonfirst/go-ioutil"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+// WATCH_MODE_NOTIFY is the watch mode for detecting changes using file system notifications (inotify, kqueue, etc.).
+const WATCH_MODE_NOTIFY string = "notify"
+
+// WATCH_MODE_POLL is the watch mode for detecting changes by periodically comparing file modification times and sizes.
+const WATCH_MODE_POLL string = "poll"
+
+// WATCH_DEBOUNCE is the default amount of time to wait after the last change to a file before yielding it.
+const WATCH_DEBOUNCE time.Duration = 500 * time.Millisecond
+
+// WATCH_POLL_INTERVAL is the default interval at which directory trees are checked for changes in "poll" mode.
+const WATCH_POLL_INTERVAL time.Duration = 5 * time.Second
+
+// WATCH_EXTENSION is the file extension of files whose changes are yielded by `WatchIterator`.
+const WATCH_EXTENSION string = ".geojson"
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "watch", NewWatchIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// WatchIterator implements the `Iterator` interface for yielding records as files are created, modified or
+// deleted in one or more directory trees.
+type WatchIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// initial is a boolean value indicating whether existing files should be yielded before watching for changes.
+	initial bool
+	// mode is the method used to detect changes; one of WATCH_MODE_NOTIFY or WATCH_MODE_POLL.
+	mode string
+	// poll_interval is the interval at which directory trees are checked for changes in "poll" mode.
+	poll_interval time.Duration
+	// debounce is the amount of time to wait after the last change to a file before yielding it.
+	debounce time.Duration
+	// idle_timeout is the amount of time to wait for changes before iteration ends. If zero changes are watched for until the context is cancelled.
+	idle_timeout time.Duration
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewWatchIterator() returns a new `WatchIterator` instance configured by 'uri' in the form of:
+//
+//	watch://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?initial=` A boolean value indicating whether existing files should be yielded, using the same walker as `DirectoryIterator`, before watching for changes.
+// * `?mode=` The method used to detect changes; "notify" or "poll". If file system notifications are not available "poll" is used. (Default is "notify".)
+// * `?poll_interval=` The number of seconds between checks for changes in "poll" mode. (Default is 5.)
+// * `?debounce=` The number of milliseconds to wait after the last change to a file before yielding it. (Default is 500.)
+// * `?idle_timeout=` The number of seconds to wait for changes before ending iteration. (Default is 0, meaning changes are watched for until the context is cancelled.)
+func NewWatchIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	it := &WatchIterator{
+		filters:       f,
+		mode:          WATCH_MODE_NOTIFY,
+		poll_interval: WATCH_POLL_INTERVAL,
+		debounce:      WATCH_DEBOUNCE,
+		seen:          int64(0),
+		iterating:     new(atomic.Bool),
+	}
+
+	if q.Has("initial") {
+
+		v, err := strconv.ParseBool(q.Get("initial"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'initial' parameter, %w", err)
+		}
+
+		it.initial = v
+	}
+
+	if q.Has("mode") {
+
+		switch q.Get("mode") {
+		case WATCH_MODE_NOTIFY, WATCH_MODE_POLL:
+			it.mode = q.Get("mode")
+		default:
+			return nil, fmt.Errorf("Invalid 'mode' parameter, must be one of '%s' or '%s'", WATCH_MODE_NOTIFY, WATCH_MODE_POLL)
+		}
+	}
+
+	// Parameter name, unit and minimum value
+
+	duration_params := []struct {
+		name   string
+		unit   time.Duration
+		min    int
+		assign func(time.Duration)
+	}{
+		{"poll_interval", time.Second, 1, func(d time.Duration) { it.poll_interval = d }},
+		{"debounce", time.Millisecond, 0, func(d time.Duration) { it.debounce = d }},
+		{"idle_timeout", time.Second, 0, func(d time.Duration) { it.idle_timeout = d }},
+	}
+
+	for _, p := range duration_params {
+
+		if !q.Has(p.name) {
+			continue
+		}
+
+		v, err := strconv.Atoi(q.Get(p.name))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '%s' parameter, %w", p.name, err)
+		}
+
+		if v < p.min {
+			return nil, fmt.Errorf("Invalid '%s' parameter, must be at least %d", p.name, p.min)
+		}
+
+		p.assign(time.Duration(v) * p.unit)
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record created, modified or deleted in the directory
+// trees defined by 'uris'. Record paths are absolute and deleted files are yielded as records whose `Deleted` property
+// is true and whose body is empty.
+func (it *WatchIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		roots := make([]string, 0)
+
+		for _, uri := range uris {
+
+			abs_path, err := filepath.Abs(uri)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to derive absolute path for '%s', %w", uri, err)) {
+					return
+				}
+
+				continue
+			}
+
+			info, err := os.Stat(abs_path)
+
+			if err != nil {
+				if !yield(nil, fmt.Errorf("Failed to stat '%s', %w", abs_path, err)) {
+					return
+				}
+
+				continue
+			}
+
+			if !info.IsDir() {
+				if !yield(nil, fmt.Errorf("'%s' is not a directory", abs_path)) {
+					return
+				}
+
+				continue
+			}
+
+			roots = append(roots, abs_path)
+		}
+
+		if len(roots) == 0 {
+			return
+		}
+
+		// Start watching before the initial pass so that changes made during the pass are not missed
+
+		var w changeWatcher
+
+		if it.mode == WATCH_MODE_NOTIFY {
+
+			nw, err := newNotifyWatcher(ctx, roots)
+
+			if err != nil {
+				slog.Warn("Failed to create file system watcher, falling back to polling", "error", err)
+			} else {
+				w = nw
+			}
+		}
+
+		if w == nil {
+
+			pw, err := newPollWatcher(ctx, roots, it.poll_interval)
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to create polling watcher, %w", err))
+				return
+			}
+
+			w = pw
+		}
+
+		defer w.Close()
+
+		if it.initial {
+
+			dir_it := &DirectoryIterator{
+				filters:   it.filters,
+				iterating: new(atomic.Bool),
+			}
+
+			for _, root := range roots {
+
+				for rec, err := range dir_it.Iterate(ctx, root) {
+
+					if err != nil {
+						if !yield(nil, err) {
+							return
+						}
+
+						continue
+					}
+
+					if !isWatchedPath(rec.Path) {
+						rec.Body.Close()
+						continue
+					}
+
+					atomic.AddInt64(&it.seen, 1)
+
+					rec.Path = filepath.Join(root, rec.Path)
+
+					if !yield(rec, nil) {
+						return
+					}
+				}
+			}
+		}
+
+		// Changes are recorded with the time they were last seen and yielded once no further changes have
+		// been seen for the debounce interval
+
+		pending := make(map[string]time.Time)
+		last_change := time.Now()
+
+		tick := max(it.debounce/2, 10*time.Millisecond)
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		changes := w.Changes()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case path, ok := <-changes:
+
+				if !ok {
+					return
+				}
+
+				if !isWatchedPath(path) {
+					continue
+				}
+
+				now := time.Now()
+				pending[path] = now
+				last_change = now
+
+			case now := <-ticker.C:
+
+				ready := make([]string, 0)
+
+				for path, t := range pending {
+					if now.Sub(t) >= it.debounce {
+						ready = append(ready, path)
+					}
+				}
+
+				slices.Sort(ready)
+
+				for _, path := range ready {
+
+					delete(pending, path)
+
+					if !it.yieldChange(ctx, path, yield) {
+						return
+					}
+
+					last_change = time.Now()
+				}
+
+				if it.idle_timeout > 0 && len(pending) == 0 && time.Since(last_change) >= it.idle_timeout {
+					return
+				}
+			}
+		}
+	}
+}
+
+// yieldChange yields a record for the file at 'path' reflecting its current state. If the file no longer exists a
+// record flagged as deleted is yielded. It returns false if iteration should stop.
+func (it *WatchIterator) yieldChange(ctx context.Context, path string, yield func(rec *Record, err error) bool) bool {
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+
+		if !os.IsNotExist(err) {
+			return yield(nil, fmt.Errorf("Failed to stat '%s', %w", path, err))
+		}
+
+		atomic.AddInt64(&it.seen, 1)
+
+		// Filters can not be applied to deleted files since there is no body to test
+
+		r, err := ioutil.NewReadSeekCloser(bytes.NewReader(nil))
+
+		if err != nil {
+			return yield(nil, fmt.Errorf("Failed to create ReadSeekCloser for '%s', %w", path, err))
+		}
+
+		rec := NewRecord(path, r)
+		rec.Deleted = true
+
+		return yield(rec, nil)
+	}
+
+	if info.IsDir() {
+		return true
+	}
+
+	atomic.AddInt64(&it.seen, 1)
+
+	// Read the file in to memory so that subsequent changes do not affect the record being yielded
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+
+		// The file was removed after it was stat-ed; its deletion will be reported separately
+		if os.IsNotExist(err) {
+			return true
+		}
+
+		return yield(nil, fmt.Errorf("Failed to read '%s', %w", path, err))
+	}
+
+	r, err := ioutil.NewReadSeekCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to create ReadSeekCloser for '%s', %w", path, err))
+	}
+
+	if it.filters != nil {
+
+		ok, err := ApplyFilters(ctx, r, it.filters)
+
+		if err != nil {
+			r.Close()
+			return yield(nil, fmt.Errorf("Failed to apply filters for '%s', %w", path, err))
+		}
+
+		if !ok {
+			r.Close()
+			return true
+		}
+	}
+
+	rec := NewRecord(path, r)
+	rec.Info = info
+
+	return yield(rec, nil)
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *WatchIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *WatchIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *WatchIterator) Close() error {
+	return nil
+}
+
+// isWatchedPath returns a boolean value indicating whether changes to 'path' should be yielded.
+func isWatchedPath(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == WATCH_EXTENSION
+}
+
+// changeWatcher is an interface for reporting the paths of files that have been created, modified or deleted.
+type changeWatcher interface {
+	// Changes returns a channel that the paths of changed files are sent to.
+	Changes() <-chan string
+	// Close stops watching for changes.
+	Close() error
+}
+
+// walkWatchedTree calls 'cb' for each file and directory in the tree rooted at 'root', skipping hidden directories
+// (for example ".git").
+func walkWatchedTree(root string, cb func(path string, d fs.DirEntry) error) error {
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+
+		if err != nil {
+
+			// Files and directories may be removed while the tree is being walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		return cb(path, d)
+	})
+}
+
+// notifyWatcher implements the `changeWatcher` interface using file system notifications.
+type notifyWatcher struct {
+	watcher *fsnotify.Watcher
+	changes chan string
+}
+
+// newNotifyWatcher returns a new `notifyWatcher` instance watching each directory in the trees rooted at 'roots'.
+func newNotifyWatcher(ctx context.Context, roots []string) (*notifyWatcher, error) {
+
+	fw, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create watcher, %w", err)
+	}
+
+	w := &notifyWatcher{
+		watcher: fw,
+		changes: make(chan string, 1024),
+	}
+
+	for _, root := range roots {
+
+		err := w.addTree(root, nil)
+
+		if err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+
+	go w.watch(ctx)
+
+	return w, nil
+}
+
+// addTree adds a watch for each directory in the tree rooted at 'root'. If 'cb' is not nil it is invoked with
+// the path of each file in the tree.
+func (w *notifyWatcher) addTree(root string, cb func(path string)) error {
+
+	return walkWatchedTree(root, func(path string, d fs.DirEntry) error {
+
+		if !d.IsDir() {
+
+			if cb != nil {
+				cb(path)
+			}
+
+			return nil
+		}
+
+		err := w.watcher.Add(path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to watch '%s', %w", path, err)
+		}
+
+		return nil
+	})
+}
+
+// watch relays file system notifications to the changes channel until 'ctx' is cancelled or the watcher is closed.
+func (w *notifyWatcher) watch(ctx context.Context) {
+
+	send := func(path string) bool {
+
+		select {
+		case <-ctx.Done():
+			return false
+		case w.changes <- path:
+			return true
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-w.watcher.Errors:
+
+			if !ok {
+				return
+			}
+
+			slog.Warn("File system watcher reported an error", "error", err)
+
+		case ev, ok := <-w.watcher.Events:
+
+			if !ok {
+				return
+			}
+
+			if ev.Has(fsnotify.Create) {
+
+				info, err := os.Stat(ev.Name)
+
+				if err == nil && info.IsDir() {
+
+					// Files may have been written to the new directory before it was watched so report them all
+
+					err := w.addTree(ev.Name, func(path string) { send(path) })
+
+					if err != nil {
+						slog.Warn("Failed to watch new directory", "path", ev.Name, "error", err)
+					}
+
+					continue
+				}
+			}
+
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) || ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+
+				if !send(ev.Name) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Changes returns a channel that the paths of changed files are sent to.
+func (w *notifyWatcher) Changes() <-chan string {
+	return w.changes
+}
+
+// Close stops watching for changes.
+func (w *notifyWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// pollState is the state of a file used to detect changes in "poll" mode.
+type pollState struct {
+	mod_time time.Time
+	size     int64
+}
+
+// pollWatcher implements the `changeWatcher` interface by periodically comparing the modification times and
+// sizes of the files in one or more directory trees.
+type pollWatcher struct {
+	roots    []string
+	interval time.Duration
+	state    map[string]pollState
+	changes  chan string
+	done_ch  chan bool
+	once     *sync.Once
+}
+
+// newPollWatcher returns a new `pollWatcher` instance checking the trees rooted at 'roots' for changes every 'interval'.
+func newPollWatcher(ctx context.Context, roots []string, interval time.Duration) (*pollWatcher, error) {
+
+	w := &pollWatcher{
+		roots:    roots,
+		interval: interval,
+		changes:  make(chan string, 1024),
+		done_ch:  make(chan bool),
+		once:     new(sync.Once),
+	}
+
+	state, err := w.snapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.state = state
+
+	go w.poll(ctx)
+
+	return w, nil
+}
+
+// snapshot returns the current state of each watched file in the trees being watched.
+func (w *pollWatcher) snapshot() (map[string]pollState, error) {
+
+	state := make(map[string]pollState)
+
+	for _, root := range w.roots {
+
+		err := walkWatchedTree(root, func(path string, d fs.DirEntry) error {
+
+			if d.IsDir() || !isWatchedPath(path) {
+				return nil
+			}
+
+			info, err := d.Info()
+
+			if err != nil {
+
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			state[path] = pollState{
+				mod_time: info.ModTime(),
+				size:     info.Size(),
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to walk '%s', %w", root, err)
+		}
+	}
+
+	return state, nil
+}
+
+// poll checks for changes every interval until 'ctx' is cancelled or the watcher is closed.
+func (w *pollWatcher) poll(ctx context.Context) {
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	send := func(path string) bool {
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-w.done_ch:
+			return false
+		case w.changes <- path:
+			return true
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.done_ch:
+			return
+		case <-ticker.C:
+
+			state, err := w.snapshot()
+
+			if err != nil {
+				slog.Warn("Failed to check for changes", "error", err)
+				continue
+			}
+
+			for path, s := range state {
+
+				prev, ok := w.state[path]
+
+				if ok && prev.mod_time.Equal(s.mod_time) && prev.size == s.size {
+					continue
+				}
+
+				if !send(path) {
+					return
+				}
+			}
+
+			for path := range w.state {
+
+				_, ok := state[path]
+
+				if ok {
+					continue
+				}
+
+				if !send(path) {
+					return
+				}
+			}
+
+			w.state = state
+		}
+	}
+}
+
+// Changes returns a channel that the paths of changed files are sent to.
+func (w *pollWatcher) Changes() <-chan string {
+	return w.changes
+}
+
+// Close stops watching for changes.
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done_ch) })
+	return nil
+}
@@ -0,0 +1,204 @@
+package iterate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type watchResult struct {
+	path    string
+	deleted bool
+}
+
+// watchRecords iterates 'path' using 'it' in a separate goroutine and returns a channel that the details of each
+// record are sent to and a channel that is closed when iteration completes.
+func watchRecords(ctx context.Context, t *testing.T, it Iterator, path string) (chan watchResult, chan bool) {
+
+	t.Helper()
+
+	results_ch := make(chan watchResult, 100)
+	done_ch := make(chan bool)
+
+	go func() {
+
+		defer close(done_ch)
+
+		for rec, err := range it.Iterate(ctx, path) {
+
+			if err != nil {
+				t.Errorf("Failed to iterate %s, %v", path, err)
+				return
+			}
+
+			body, err := io.ReadAll(rec.Body)
+			rec.Body.Close()
+
+			if err != nil {
+				t.Errorf("Failed to read body for %s, %v", rec.Path, err)
+				return
+			}
+
+			if !rec.Deleted && len(body) == 0 {
+				t.Errorf("Empty body for %s", rec.Path)
+				return
+			}
+
+			results_ch <- watchResult{path: rec.Path, deleted: rec.Deleted}
+		}
+	}()
+
+	return results_ch, done_ch
+}
+
+func waitForWatchResult(t *testing.T, results_ch chan watchResult, expected watchResult) {
+
+	t.Helper()
+
+	select {
+	case r := <-results_ch:
+
+		if r != expected {
+			t.Fatalf("Unexpected result. Got %v but expected %v", r, expected)
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for %v", expected)
+	}
+}
+
+func TestWatchIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	body, err := os.ReadFile("fixtures/data/174/657/420/7/1746574207.geojson")
+
+	if err != nil {
+		t.Fatalf("Failed to read fixture, %v", err)
+	}
+
+	for _, mode := range []string{WATCH_MODE_NOTIFY, WATCH_MODE_POLL} {
+
+		t.Run(mode, func(t *testing.T) {
+
+			root := t.TempDir()
+
+			a_path := filepath.Join(root, "a.geojson")
+			b_path := filepath.Join(root, "sub", "b.geojson")
+
+			for _, path := range []string{a_path, filepath.Join(root, "a.txt")} {
+
+				err := os.WriteFile(path, body, 0644)
+
+				if err != nil {
+					t.Fatalf("Failed to write %s, %v", path, err)
+				}
+			}
+
+			iter_uri := fmt.Sprintf("watch://?initial=true&mode=%s&poll_interval=1&debounce=300&idle_timeout=2", mode)
+
+			it, err := NewIterator(ctx, iter_uri)
+
+			if err != nil {
+				t.Fatalf("Failed to create new watch source, %v", err)
+			}
+
+			results_ch, done_ch := watchRecords(ctx, t, it, root)
+
+			// Initial pass
+
+			waitForWatchResult(t, results_ch, watchResult{path: a_path})
+
+			// New files in new directories; files without a .geojson extension are ignored
+
+			err = os.MkdirAll(filepath.Dir(b_path), 0755)
+
+			if err != nil {
+				t.Fatalf("Failed to create directory, %v", err)
+			}
+
+			for _, path := range []string{b_path, filepath.Join(root, "sub", "b.txt")} {
+
+				err := os.WriteFile(path, body, 0644)
+
+				if err != nil {
+					t.Fatalf("Failed to write %s, %v", path, err)
+				}
+			}
+
+			waitForWatchResult(t, results_ch, watchResult{path: b_path})
+
+			// Rapid successive writes
+
+			for i := 0; i < 5; i++ {
+
+				err := os.WriteFile(a_path, append(body, '\n'), 0644)
+
+				if err != nil {
+					t.Fatalf("Failed to write %s, %v", a_path, err)
+				}
+
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			waitForWatchResult(t, results_ch, watchResult{path: a_path})
+
+			// Deletions
+
+			err = os.Remove(b_path)
+
+			if err != nil {
+				t.Fatalf("Failed to remove %s, %v", b_path, err)
+			}
+
+			waitForWatchResult(t, results_ch, watchResult{path: b_path, deleted: true})
+
+			select {
+			case <-done_ch:
+				// pass
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Timed out waiting for iteration to complete")
+			}
+
+			if len(results_ch) != 0 {
+				t.Fatalf("Unexpected records, %v", <-results_ch)
+			}
+
+			if it.Seen() != 4 {
+				t.Fatalf("Unexpected record count. Got %d but expected 4", it.Seen())
+			}
+		})
+	}
+}
+
+func TestNewWatchIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	// URIs and whether they are valid
+
+	tests := map[string]bool{
+		"watch://":                   true,
+		"watch://?initial=true":      true,
+		"watch://?mode=poll":         true,
+		"watch://?mode=inotify":      false,
+		"watch://?poll_interval=0":   false,
+		"watch://?debounce=0":        true,
+		"watch://?debounce=-1":       false,
+		"watch://?idle_timeout=ten":  false,
+		"watch://?initial=sometimes": false,
+	}
+
+	for uri, expected := range tests {
+
+		_, err := NewWatchIterator(ctx, uri)
+
+		if (err == nil) != expected {
+			t.Fatalf("Unexpected result for '%s', %v", uri, err)
+		}
+	}
+}
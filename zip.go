@@ -0,0 +1,421 @@
+package iterate
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3/filters"
+)
+
+func init() {
+	ctx := context.Background()
+	err := RegisterIterator(ctx, "zip", NewZipIterator)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ZipIterator implements the `Iterator` interface for crawling GeoJSON records in a zip archive.
+type ZipIterator struct {
+	Iterator
+	// filters is a `filters.Filters` instance used to include or exclude specific records from being crawled.
+	filters filters.Filters
+	// processes is the maximum number of archive entries to process simultaneously.
+	processes int
+	// seen is the count of documents that have been processed.
+	seen int64
+	// iterating is a boolean value indicating whether records are still being iterated.
+	iterating *atomic.Bool
+}
+
+// NewZipIterator() returns a new `ZipIterator` instance configured by 'uri' in the form of:
+//
+//	zip://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?include=` Zero or more `aaronland/go-json-query` query strings containing rules that must match for a document to be considered for further processing.
+// * `?exclude=` Zero or more `aaronland/go-json-query`	query strings containing rules that if matched will prevent a document from being considered for further processing.
+// * `?include_mode=` A valid `aaronland/go-json-query` query mode string for testing inclusion rules.
+// * `?exclude_mode=` A valid `aaronland/go-json-query` query mode string for testing exclusion rules.
+// * `?processes=` An optional number assigning the maximum number of archive entries that will be processed simultaneously. (Default is 1.)
+//
+// Only entries with a ".geojson" extension are processed. The modification time and uncompressed size of each entry
+// are available via the `Info` property of the records that are yielded.
+func NewZipIterator(ctx context.Context, uri string) (Iterator, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	f, err := filters.NewQueryFiltersFromQuery(ctx, q)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create filters from query, %w", err)
+	}
+
+	processes := 1
+
+	if q.Has("processes") {
+
+		v, err := strconv.Atoi(q.Get("processes"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse 'processes' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid 'processes' parameter, must be greater than zero")
+		}
+
+		processes = v
+	}
+
+	it := &ZipIterator{
+		filters:   f,
+		processes: processes,
+		seen:      int64(0),
+		iterating: new(atomic.Bool),
+	}
+
+	return it, nil
+}
+
+// Iterate will return an `iter.Seq2[*Record, error]` for each record encountered in 'uris'.
+func (it *ZipIterator) Iterate(ctx context.Context, uris ...string) iter.Seq2[*Record, error] {
+
+	return func(yield func(rec *Record, err error) bool) {
+
+		it.iterating.Swap(true)
+		defer it.iterating.Swap(false)
+
+		for _, uri := range uris {
+
+			if !it.iterateArchive(ctx, uri, yield) {
+				return
+			}
+		}
+	}
+}
+
+// iterateArchive yields records for each ".geojson" entry in the zip archive at 'uri'. It returns false
+// if 'yield' has signaled that iteration should stop.
+func (it *ZipIterator) iterateArchive(ctx context.Context, uri string, yield func(rec *Record, err error) bool) bool {
+
+	logger := slog.Default()
+	logger = logger.With("uri", uri)
+
+	zip_r, err := zip.OpenReader(uri)
+
+	if err != nil {
+		return yield(nil, fmt.Errorf("Failed to open '%s', %w", uri, err))
+	}
+
+	// The archive remains open until both this method and all the record bodies it produced have been closed.
+
+	archive := newZipArchive(zip_r)
+	defer archive.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files_ch := make(chan *zip.File)
+	rec_ch := make(chan *Record)
+	err_ch := make(chan error)
+
+	go func() {
+
+		defer close(files_ch)
+
+		for _, f := range zip_r.File {
+
+			if f.FileInfo().IsDir() {
+				continue
+			}
+
+			if strings.ToLower(filepath.Ext(f.Name)) != ".geojson" {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case files_ch <- f:
+				// pass
+			}
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < it.processes; i++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for f := range files_ch {
+
+				logger.Debug("Process entry", "path", f.Name)
+
+				rec, err := it.processFile(ctx, archive, f)
+
+				if err != nil {
+
+					select {
+					case <-ctx.Done():
+						return
+					case err_ch <- err:
+						continue
+					}
+				}
+
+				if rec == nil {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					rec.Body.Close()
+					return
+				case rec_ch <- rec:
+					// pass
+				}
+			}
+		}()
+	}
+
+	done_ch := make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done_ch)
+	}()
+
+	for {
+		select {
+		case <-done_ch:
+			return true
+		case err := <-err_ch:
+			if !yield(nil, err) {
+				return false
+			}
+		case rec := <-rec_ch:
+			if !yield(rec, nil) {
+				return false
+			}
+		}
+	}
+}
+
+// processFile returns a new `Record` instance for 'f' or nil if the record has been excluded by the iterator's filters.
+func (it *ZipIterator) processFile(ctx context.Context, archive *zipArchive, f *zip.File) (*Record, error) {
+
+	atomic.AddInt64(&it.seen, 1)
+
+	r := newZipFileReader(archive, f)
+
+	if it.filters != nil {
+
+		ok, err := ApplyFilters(ctx, r, it.filters)
+
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("Failed to apply filters for '%s', %w", f.Name, err)
+		}
+
+		if !ok {
+			r.Close()
+			return nil, nil
+		}
+	}
+
+	rec := NewRecord(f.Name, r)
+	rec.Info = f.FileInfo()
+
+	return rec, nil
+}
+
+// Seen() returns the total number of records processed so far.
+func (it *ZipIterator) Seen() int64 {
+	return atomic.LoadInt64(&it.seen)
+}
+
+// IsIterating() returns a boolean value indicating whether 'it' is still processing documents.
+func (it *ZipIterator) IsIterating() bool {
+	return it.iterating.Load()
+}
+
+// Close performs any implementation specific tasks before terminating the iterator.
+func (it *ZipIterator) Close() error {
+	return nil
+}
+
+// zipArchive is a reference-counted wrapper around a `zip.ReadCloser` instance.
+type zipArchive struct {
+	reader *zip.ReadCloser
+	refs   int64
+}
+
+func newZipArchive(r *zip.ReadCloser) *zipArchive {
+
+	a := &zipArchive{
+		reader: r,
+		refs:   int64(1),
+	}
+
+	return a
+}
+
+func (a *zipArchive) retain() {
+	atomic.AddInt64(&a.refs, 1)
+}
+
+func (a *zipArchive) release() error {
+
+	if atomic.AddInt64(&a.refs, -1) == 0 {
+		return a.reader.Close()
+	}
+
+	return nil
+}
+
+// zipFileReader implements the `io.ReadSeekCloser` interface for an entry in a zip archive. Since zip
+// archives allow for random access seeking is accomplished by (re)opening the entry and discarding any
+// bytes before the desired offset, rather than by buffering the entire entry in memory.
+type zipFileReader struct {
+	archive *zipArchive
+	file    *zip.File
+	reader  io.ReadCloser
+	offset  int64
+	closed  bool
+}
+
+func newZipFileReader(archive *zipArchive, f *zip.File) *zipFileReader {
+
+	archive.retain()
+
+	r := &zipFileReader{
+		archive: archive,
+		file:    f,
+	}
+
+	return r
+}
+
+// Read implements the `io.Reader` interface.
+func (r *zipFileReader) Read(p []byte) (int, error) {
+
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if r.reader == nil {
+
+		err := r.open(r.offset)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+// Seek implements the `io.Seeker` interface.
+func (r *zipFileReader) Seek(offset int64, whence int) (int64, error) {
+
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = int64(r.file.UncompressedSize64) + offset
+	default:
+		return 0, fmt.Errorf("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, fmt.Errorf("Negative position")
+	}
+
+	if abs == r.offset {
+		return abs, nil
+	}
+
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// Close implements the `io.Closer` interface.
+func (r *zipFileReader) Close() error {
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+
+	return r.archive.release()
+}
+
+// open (re)opens the underlying zip entry and advances it to 'offset'.
+func (r *zipFileReader) open(offset int64) error {
+
+	rc, err := r.file.Open()
+
+	if err != nil {
+		return fmt.Errorf("Failed to open '%s', %w", r.file.Name, err)
+	}
+
+	if offset > 0 {
+
+		_, err := io.CopyN(io.Discard, rc, offset)
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			rc.Close()
+			return fmt.Errorf("Failed to advance '%s' to offset %d, %w", r.file.Name, offset, err)
+		}
+	}
+
+	r.reader = rc
+	return nil
+}
@@ -0,0 +1,86 @@
+package iterate
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipIterator(t *testing.T) {
+
+	if *tests_verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Debug("Verbose logging enabled")
+	}
+
+	ctx := context.Background()
+
+	abs_path, err := filepath.Abs("testdata/archives/data.zip")
+
+	if err != nil {
+		t.Fatalf("Failed to derive absolute path for fixtures, %v", err)
+	}
+
+	for _, iterator_uri := range []string{"zip://", "zip://?processes=4"} {
+
+		it, err := NewIterator(ctx, iterator_uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create new zip source, %v", err)
+		}
+
+		for rec, err := range it.Iterate(ctx, abs_path) {
+
+			if err != nil {
+				t.Fatalf("Failed to walk '%s', %v", abs_path, err)
+				break
+			}
+
+			defer rec.Body.Close()
+			body, err := io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed to read body for %s, %v", rec.Path, err)
+			}
+
+			if rec.Info == nil {
+				t.Fatalf("Missing file info for %s", rec.Path)
+			}
+
+			if rec.Info.Size() != int64(len(body)) {
+				t.Fatalf("Unexpected size for %s. Got %d but expected %d", rec.Path, rec.Info.Size(), len(body))
+			}
+
+			if rec.Info.ModTime().IsZero() {
+				t.Fatalf("Missing modification time for %s", rec.Path)
+			}
+
+			_, err = rec.Body.Seek(0, 0)
+
+			if err != nil {
+				t.Fatalf("Failed to rewind body for %s, %v", rec.Path, err)
+			}
+
+			_, err = io.ReadAll(rec.Body)
+
+			if err != nil {
+				t.Fatalf("Failed second read body for %s, %v", rec.Path, err)
+			}
+		}
+
+		seen := it.Seen()
+		expected := int64(37)
+
+		if seen != expected {
+			t.Fatalf("Unexpected record count for %s. Got %d but expected %d", iterator_uri, seen, expected)
+		}
+
+		err = it.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to close iterator")
+		}
+	}
+}